@@ -1,23 +1,45 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
-	"os"
+	"os/signal"
+	"syscall"
 	"tg-video-bot/internal/bot"
 	"tg-video-bot/internal/database"
 )
 
 func main() {
-	// Инициализация БД
-	db, err := database.InitDB()
-	if err != nil {
-		log.Fatal("Database initialization failed:", err)
+	dryRun := flag.Bool("dry-run", false, "хранить данные в памяти, без подключения к БД")
+	flag.Parse()
+
+	// Остановка по SIGINT/SIGTERM (docker stop)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Инициализация хранилища (DB_DRIVER=mysql|sqlite|memory)
+	var store database.Store
+	if *dryRun {
+		log.Println("Dry run: using in-memory store")
+		store = database.NewMemoryStore()
+	} else {
+		var err error
+		store, err = database.OpenStore()
+		if err != nil {
+			log.Fatal("Database initialization failed:", err)
+		}
 	}
-	defer db.Close()
 
-	// Запуск бота
-	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if err := bot.Start(botToken, db); err != nil {
+	// Запуск бота; возвращается после остановки приема апдейтов
+	// и завершения обработчиков
+	err := bot.Start(ctx, bot.LoadConfig(), store)
+
+	if cerr := store.Close(); cerr != nil {
+		log.Printf("Failed to close database: %v", cerr)
+	}
+	if err != nil {
 		log.Fatal("Bot failed:", err)
 	}
+	log.Println("Bot stopped")
 }
@@ -5,9 +5,14 @@ go 1.22.12
 require (
 	github.com/go-sql-driver/mysql v1.9.2
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
+	github.com/ncruces/go-sqlite3 v0.17.1
+	github.com/tetratelabs/wazero v1.7.3
+	golang.org/x/text v0.16.0
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/ncruces/julianday v1.0.0 // indirect
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	golang.org/x/sys v0.22.0 // indirect
 )
@@ -0,0 +1,337 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"tg-video-bot/internal/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// defaultAlbumWindow — сколько ждать следующий файл альбома. Telegram
+// присылает части альбома отдельными сообщениями почти одновременно
+const defaultAlbumWindow = 1500 * time.Millisecond
+
+// albumBuffer копит сообщения с одним media_group_id, пока они приходят
+type albumBuffer struct {
+	mu      sync.Mutex
+	pending map[string]*pendingAlbum
+}
+
+// pendingAlbum — еще не сохраненный альбом
+type pendingAlbum struct {
+	items []albumItem
+	timer *time.Timer
+}
+
+type albumItem struct {
+	// updateID — апдейт с файлом; он считается обработанным только после
+	// сохранения альбома, чтобы файл не пропал при перезапуске
+	updateID int
+	msg      *tgbotapi.Message
+	media    models.Video
+	extra    MessageExtra
+}
+
+func newAlbumBuffer() *albumBuffer {
+	return &albumBuffer{pending: make(map[string]*pendingAlbum)}
+}
+
+// add добавляет файл в альбом и откладывает flush на window после
+// последнего полученного файла
+func (a *albumBuffer) add(key string, item albumItem, window time.Duration, flush func(key string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	album := a.pending[key]
+	if album == nil {
+		album = &pendingAlbum{}
+		album.timer = time.AfterFunc(window, func() { flush(key) })
+		a.pending[key] = album
+	} else {
+		album.timer.Reset(window)
+	}
+	album.items = append(album.items, item)
+}
+
+// holds проверяет, ждет ли файл из апдейта updateID сохранения альбома
+func (a *albumBuffer) holds(updateID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, album := range a.pending {
+		for _, item := range album.items {
+			if item.updateID == updateID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// take забирает накопленные файлы альбома
+func (a *albumBuffer) take(key string) []albumItem {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	album := a.pending[key]
+	if album == nil {
+		return nil
+	}
+	album.timer.Stop()
+	delete(a.pending, key)
+	return album.items
+}
+
+// keys возвращает все ожидающие альбомы
+func (a *albumBuffer) keys() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	keys := make([]string, 0, len(a.pending))
+	for key := range a.pending {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// bufferAlbumItem откладывает файл альбома до прихода остальных частей
+func (b *Bot) bufferAlbumItem(update Update, media models.Video) {
+	msg := update.Message
+	key := fmt.Sprintf("%d:%s", msg.Chat.ID, update.Extra.MediaGroupID)
+	item := albumItem{updateID: update.UpdateID, msg: msg, media: media, extra: update.Extra}
+	b.albums.add(key, item, b.AlbumWindow, func(key string) { b.scheduleAlbumFlush(msg.Chat.ID, key) })
+}
+
+// scheduleAlbumFlush ставит сохранение альбома в очередь его чата, чтобы
+// сводка и диалог тегов не обгоняли сообщения, пришедшие до них, и не
+// выполнялись одновременно с ними. Если очереди уже закрыты, альбом
+// сохранит flushAlbums при остановке
+func (b *Bot) scheduleAlbumFlush(chatID int64, key string) {
+	flush := func() { b.flushAlbum(key) }
+	if b.dispatcher == nil {
+		flush()
+		return
+	}
+	b.dispatcher.Submit(chatID, flush)
+}
+
+// flushAlbum сохраняет накопленный альбом и завершает апдейты с его
+// файлами, даже если сохранение упало
+func (b *Bot) flushAlbum(key string) {
+	items := b.albums.take(key)
+	if len(items) == 0 {
+		return
+	}
+	defer b.completeAlbum(items)
+	defer b.recoverPanic("album "+key, 0)
+
+	b.saveAlbum(items)
+}
+
+// completeAlbum отмечает апдейты с файлами альбома обработанными, и offset
+// может сдвинуться дальше них
+func (b *Bot) completeAlbum(items []albumItem) {
+	for _, item := range items {
+		b.markProcessed(item.updateID)
+		if b.dispatcher != nil {
+			b.dispatcher.offsets.Done(item.updateID)
+		}
+	}
+}
+
+// flushAlbums сохраняет все ожидающие альбомы, не дожидаясь таймеров.
+// Вызывается при остановке бота, когда очереди уже закрыты
+func (b *Bot) flushAlbums() {
+	for _, key := range b.albums.keys() {
+		b.flushAlbum(key)
+	}
+}
+
+// saveAlbum сохраняет файлы альбома с общими подписью и тегами и
+// отвечает одной сводкой. Подпись Telegram прикрепляет к одному из файлов
+func (b *Bot) saveAlbum(items []albumItem) {
+	sort.Slice(items, func(i, j int) bool { return items[i].msg.MessageID < items[j].msg.MessageID })
+	first := items[0].msg
+
+	var caption string
+	var tags []string
+	for _, item := range items {
+		if item.msg.Caption == "" {
+			continue
+		}
+		tags, caption = captionHashtags(item.msg.Caption, item.extra.CaptionEntities)
+		if !b.StripCaptionHashtags {
+			caption = item.msg.Caption
+		}
+		break
+	}
+
+	album := models.Album{Caption: caption}
+	for _, item := range items {
+		video := item.media
+		video.Caption = caption
+		video.Tags = tags
+		video.UploaderID = senderID(item.msg)
+		album.Videos = append(album.Videos, video)
+	}
+
+	saved, duplicates, err := b.Store.SaveAlbum(album)
+	if err != nil {
+		log.Printf("Ошибка сохранения альбома: %v", err)
+		b.SendMessage(first.Chat.ID, "❌ Ошибка сохранения альбома")
+		return
+	}
+
+	var text strings.Builder
+	if saved.ID != 0 {
+		ids := make([]string, len(saved.Videos))
+		for i, v := range saved.Videos {
+			ids[i] = strconv.FormatInt(v.ID, 10)
+		}
+		fmt.Fprintf(&text, "✅ Сохранен альбом (ID: %d)\nФайлы: %s", saved.ID, strings.Join(ids, ", "))
+	}
+	if len(duplicates) > 0 {
+		ids := make([]string, len(duplicates))
+		for i, id := range duplicates {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		if text.Len() > 0 {
+			text.WriteString("\n")
+		}
+		fmt.Fprintf(&text, "⚠️ Уже есть в базе: ID %s", strings.Join(ids, ", "))
+	}
+
+	if saved.ID == 0 || len(tags) > 0 {
+		if len(tags) > 0 {
+			fmt.Fprintf(&text, "\nТеги: %s", strings.Join(tags, ", "))
+		}
+		b.SendMessage(first.Chat.ID, text.String())
+		return
+	}
+
+	text.WriteString("\nОтправьте теги для всего альбома через пробел или /cancel")
+	b.startDialog(first, stateUploadTags, map[string]string{"album_id": strconv.FormatInt(saved.ID, 10)}, text.String(), nil)
+}
+
+// addAlbumTags добавляет теги ко всем файлам альбома
+func (b *Bot) addAlbumTags(chatID int64, albumIDStr string, tags []string) {
+	albumID, _ := strconv.ParseInt(albumIDStr, 10, 64)
+	videos, err := b.Store.GetAlbumVideos(albumID)
+	if err != nil || len(videos) == 0 {
+		log.Printf("Failed to get album %d: %v", albumID, err)
+		b.SendMessage(chatID, "❌ Альбом не найден")
+		return
+	}
+
+	for _, v := range videos {
+		if err := b.Store.AddTagsToVideo(v.ID, tags); err != nil {
+			log.Printf("Ошибка добавления тегов: %v", err)
+			b.SendMessage(chatID, "❌ Ошибка добавления тегов")
+			return
+		}
+	}
+
+	b.SendMessage(chatID, fmt.Sprintf("✅ Добавлены теги к альбому: %s", strings.Join(tags, ", ")))
+}
+
+// deliver отправляет элемент коллекции. Файл из альбома уходит вместе с
+// остальными файлами альбома одним sendMediaGroup; кнопки markup в этом
+// случае приходят отдельным сообщением, потому что у альбома их нет.
+// Возвращает ID всех отправленных файлов
+func (b *Bot) deliver(chatID int64, v models.Video, markup interface{}) ([]int64, error) {
+	if v.AlbumID != 0 {
+		videos, err := b.Store.GetAlbumVideos(v.AlbumID)
+		if err != nil {
+			log.Printf("Failed to get album %d: %v", v.AlbumID, err)
+		} else if canGroup(videos) {
+			if err := b.sendMediaGroup(chatID, videos); err != nil {
+				return nil, err
+			}
+			if markup != nil {
+				reply := tgbotapi.NewMessage(chatID, "Теги альбома:")
+				reply.ReplyMarkup = markup
+				if _, err := b.API.Send(reply); err != nil {
+					log.Printf("Failed to send album tags to chat %d: %v", chatID, err)
+				}
+			}
+
+			ids := make([]int64, len(videos))
+			for i, video := range videos {
+				ids[i] = video.ID
+			}
+			return ids, nil
+		}
+	}
+
+	if err := b.sendMedia(chatID, v, markup); err != nil {
+		return nil, err
+	}
+	return []int64{v.ID}, nil
+}
+
+// canGroup проверяет, что файлы можно отправить одним альбомом: от 2 до 10
+// фото и видео либо только документы
+func canGroup(videos []models.Video) bool {
+	if len(videos) < 2 || len(videos) > 10 {
+		return false
+	}
+	documents := 0
+	for _, v := range videos {
+		switch v.Type {
+		case models.MediaDocument:
+			documents++
+		case models.MediaPhoto, models.MediaVideo, "":
+		default:
+			return false
+		}
+	}
+	return documents == 0 || documents == len(videos)
+}
+
+// inputMedia — элемент sendMediaGroup. В tgbotapi v4 нет документов в
+// альбомах, поэтому структура общая для всех типов
+type inputMedia struct {
+	Type    string `json:"type"`
+	Media   string `json:"media"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// sendMediaGroup отправляет файлы одним альбомом. tgbotapi v4 ждет в ответе
+// одно сообщение, а Telegram возвращает массив, поэтому запрос идет через
+// MakeRequest
+func (b *Bot) sendMediaGroup(chatID int64, videos []models.Video) error {
+	media := make([]inputMedia, len(videos))
+	for i, v := range videos {
+		media[i] = inputMedia{Type: string(v.Type), Media: v.FileID}
+		if media[i].Type == "" {
+			media[i].Type = string(models.MediaVideo)
+		}
+	}
+	// Подпись первого файла становится подписью альбома
+	media[0].Caption = videos[0].Caption
+
+	data, err := json.Marshal(media)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	params.Set("media", string(data))
+
+	resp, err := b.API.MakeRequest("sendMediaGroup", params)
+	if err != nil {
+		return err
+	}
+
+	var messages []tgbotapi.Message
+	return json.Unmarshal(resp.Result, &messages)
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// TestAlbumHeldUntilSaved проверяет, что апдейты с файлами альбома не
+// подтверждаются, пока альбом не сохранен, и что сохранение идет в
+// очереди чата: сводка приходит после ответов на более ранние сообщения,
+// а диалог тегов продолжается следующим сообщением
+func TestAlbumHeldUntilSaved(t *testing.T) {
+	srv, b := newTestBot(t, nil)
+	b.AlbumWindow = 300 * time.Millisecond
+	offsets := startPolling(t, srv, b)
+
+	// Апдейты 1 и 2 — файлы альбома, 3 — команда после него
+	srv.PushAlbum(testAdminGroup, testOwner, "g1", []string{"a1", "a2"}, "")
+	srv.PushMessage(testAdminGroup, testOwner, "/help")
+	waitRequest(t, srv, "sendMessage", 1)
+
+	for id := 1; id <= 2; id++ {
+		if processed, _ := b.Store.IsUpdateProcessed(b.ID, id); processed {
+			t.Fatalf("update %d marked processed before the album was saved", id)
+		}
+	}
+	if last := offsets.Last(); last != 0 {
+		t.Fatalf("offset moved to %d before the album was saved", last)
+	}
+
+	summary := waitRequest(t, srv, "sendMessage", 2)
+	if text := summary.Params.Get("text"); !strings.Contains(text, "Сохранен альбом") {
+		t.Fatalf("second reply = %q, want album summary after /help", text)
+	}
+	// Апдейты завершаются сразу после отправки сводки
+	for deadline := time.Now().Add(waitTimeout); offsets.Last() < 3; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("offset = %d after the album was saved, want 3", offsets.Last())
+		}
+	}
+	for id := 1; id <= 2; id++ {
+		if processed, _ := b.Store.IsUpdateProcessed(b.ID, id); !processed {
+			t.Fatalf("update %d not marked processed after the album was saved", id)
+		}
+	}
+
+	srv.PushMessage(testAdminGroup, testOwner, "котики")
+	tagged := waitRequest(t, srv, "sendMessage", 3)
+	if got, want := tagged.Params.Get("text"), "✅ Добавлены теги к альбому: котики"; got != want {
+		t.Fatalf("tag reply = %q, want %q", got, want)
+	}
+}
+
+// TestAlbumSavedOnShutdown проверяет, что альбом, который не дождался
+// таймера, сохраняется при остановке и входит в итоговый offset
+func TestAlbumSavedOnShutdown(t *testing.T) {
+	srv, b := newTestBot(t, nil)
+	b.AlbumWindow = time.Hour
+
+	updates := make(chan Update)
+	done := make(chan int)
+	go func() { done <- b.Run(updates, 0) }()
+
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.PushAlbum(testAdminGroup, testOwner, "g1", []string{"a1", "a2"}, "#котики")
+	fetched, err := getUpdates(api, tgbotapi.NewUpdate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, update := range fetched {
+		updates <- update
+	}
+	close(updates)
+
+	if last := <-done; last != len(fetched) {
+		t.Fatalf("Run returned offset %d, want %d", last, len(fetched))
+	}
+	summary := waitRequest(t, srv, "sendMessage", 1)
+	if text := summary.Params.Get("text"); !strings.Contains(text, "Сохранен альбом") {
+		t.Fatalf("reply = %q, want album summary", text)
+	}
+}
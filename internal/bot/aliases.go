@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"tg-video-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// HandleAliasesCommand обрабатывает /aliases: псевдонимы по тегам
+func (b *Bot) HandleAliasesCommand(msg *tgbotapi.Message) {
+	aliases, err := b.Store.ListTagAliases()
+	if err != nil {
+		log.Printf("Failed to list tag aliases: %v", err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка получения псевдонимов")
+		return
+	}
+	if len(aliases) == 0 {
+		b.SendMessage(msg.Chat.ID, "Псевдонимов пока нет. Добавить: /alias псевдоним... тег")
+		return
+	}
+
+	// Список уже отсортирован по тегу, собираем псевдонимы одного тега в строку
+	var text strings.Builder
+	text.WriteString("🔗 Псевдонимы тегов:\n")
+	for i, a := range aliases {
+		if i == 0 || aliases[i-1].Tag != a.Tag {
+			fmt.Fprintf(&text, "\n#%s: %s", a.Tag, a.Alias)
+		} else {
+			fmt.Fprintf(&text, ", %s", a.Alias)
+		}
+	}
+	b.SendMessage(msg.Chat.ID, text.String())
+}
+
+// HandleAliasCommand обрабатывает /alias псевдоним... тег: все слова,
+// кроме последнего, становятся псевдонимами последнего тега
+func (b *Bot) HandleAliasCommand(msg *tgbotapi.Message) {
+	args := tagArgs(msg.CommandArguments())
+	if len(args) < 2 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /alias псевдоним... тег\nПример: /alias кошки cats котики")
+		return
+	}
+	aliases, tag := args[:len(args)-1], args[len(args)-1]
+
+	canonical, ok, err := b.Store.LookupTag(tag)
+	if err != nil {
+		log.Printf("Failed to look up tag %q: %v", tag, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка добавления псевдонимов")
+		return
+	}
+	if !ok {
+		b.SendMessage(msg.Chat.ID, b.tagNotFound(tag))
+		return
+	}
+
+	var added []string
+	for _, alias := range aliases {
+		err := b.Store.AddTagAlias(alias, canonical)
+		switch {
+		case errors.Is(err, database.ErrTagExists):
+			b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ #%s — отдельный тег. Объединить теги: /merge_tags %s %s", alias, alias, canonical))
+		case err != nil:
+			log.Printf("Failed to add alias %q for tag %q: %v", alias, canonical, err)
+			b.SendMessage(msg.Chat.ID, "❌ Ошибка добавления псевдонима "+alias)
+		default:
+			added = append(added, alias)
+		}
+	}
+	if len(added) > 0 {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ Псевдонимы #%s: %s", canonical, strings.Join(added, ", ")))
+	}
+}
+
+// HandleUnaliasCommand обрабатывает /unalias псевдоним...
+func (b *Bot) HandleUnaliasCommand(msg *tgbotapi.Message) {
+	aliases := tagArgs(msg.CommandArguments())
+	if len(aliases) == 0 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /unalias псевдоним...")
+		return
+	}
+
+	var removed, missing []string
+	for _, alias := range aliases {
+		err := b.Store.DeleteTagAlias(alias)
+		switch {
+		case errors.Is(err, database.ErrAliasNotFound):
+			missing = append(missing, alias)
+		case err != nil:
+			log.Printf("Failed to delete alias %q: %v", alias, err)
+			b.SendMessage(msg.Chat.ID, "❌ Ошибка удаления псевдонима "+alias)
+		default:
+			removed = append(removed, alias)
+		}
+	}
+
+	var lines []string
+	if len(removed) > 0 {
+		lines = append(lines, "✅ Удалены псевдонимы: "+strings.Join(removed, ", "))
+	}
+	if len(missing) > 0 {
+		lines = append(lines, "❌ Нет таких псевдонимов: "+strings.Join(missing, ", "))
+	}
+	if len(lines) > 0 {
+		b.SendMessage(msg.Chat.ID, strings.Join(lines, "\n"))
+	}
+}
+
+// tagNotFound сообщает, что тега нет, и подсказывает тег, который поиск
+// нашел бы по транслитерации
+func (b *Bot) tagNotFound(tag string) string {
+	text := fmt.Sprintf("❌ Тег #%s не найден", tag)
+	suggestion, ok, err := b.Store.ResolveTag(tag)
+	if err != nil {
+		log.Printf("Failed to resolve tag %q: %v", tag, err)
+	}
+	if ok {
+		text += fmt.Sprintf(". Возможно, #%s?", suggestion)
+	}
+	return text
+}
@@ -1,22 +1,43 @@
 package bot
 
 import (
-	"fmt"
-	"os"
+	"log"
 	"strconv"
 	"strings"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
-func (b *Bot) IsAdmin(userID int64) bool {
-	adminIDsStr := os.Getenv("ADMIN_IDS")
-	if adminIDsStr == "" {
-		return false
+// roleOf возвращает роль пользователя из базы. Если база недоступна,
+// пользователь получает права viewer
+func (b *Bot) roleOf(userID int64) models.Role {
+	if userID == 0 {
+		return models.RoleViewer
 	}
 
-	adminIDs := strings.Split(adminIDsStr, ",")
-	for _, idStr := range adminIDs {
-		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
-		if err == nil && id == int64(userID) {
+	role, err := b.Store.GetUserRole(userID)
+	if err != nil {
+		log.Printf("Failed to get role of user %d: %v", userID, err)
+		return models.RoleViewer
+	}
+	return role
+}
+
+// can проверяет, что у пользователя есть роль не ниже required
+func (b *Bot) can(userID int64, required models.Role) bool {
+	return b.roleOf(userID).AtLeast(required)
+}
+
+// userRole возвращает роль автора сообщения
+func (b *Bot) userRole(msg *tgbotapi.Message) models.Role {
+	return b.roleOf(senderID(msg))
+}
+
+// IsAdminGroup проверяет, что группа входит в ADMIN_GROUP_IDS
+func (b *Bot) IsAdminGroup(groupID int64) bool {
+	for _, id := range b.AdminGroups {
+		if id == groupID {
 			return true
 		}
 	}
@@ -24,20 +45,38 @@ func (b *Bot) IsAdmin(userID int64) bool {
 	return false
 }
 
-func (b *Bot) IsAdminGroup(groupID int64) bool {
-	adminIDsStr := os.Getenv("ADMIN_GROUP_IDS")
-	fmt.Println("adminIDsStr:", adminIDsStr, "groupID:", groupID)
-	if adminIDsStr == "" {
-		return false
+// BootstrapOwners выдает роль owner пользователям из ADMIN_IDS, заменяя
+// роль, сохраненную раньше. Так первый владелец появляется без ручного
+// редактирования базы. Роль этих владельцев задается конфигурацией:
+// /grant и /revoke ее не меняют, а выдавать роль owner другим могут только они
+func (b *Bot) BootstrapOwners(ids []int64) error {
+	b.owners = ids
+	for _, id := range ids {
+		if err := b.Store.SetUserRole(id, models.RoleOwner, 0); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	adminIDs := strings.Split(adminIDsStr, ",")
-	for _, idStr := range adminIDs {
-		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
-		if err == nil && id == groupID {
+// isBootstrapOwner проверяет, что пользователь — владелец из ADMIN_IDS
+func (b *Bot) isBootstrapOwner(userID int64) bool {
+	for _, id := range b.owners {
+		if id == userID {
 			return true
 		}
 	}
-
 	return false
 }
+
+// parseIDs разбирает список ID через запятую, пропуская некорректные
+func parseIDs(s string) []int64 {
+	var ids []int64
+	for _, idStr := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -1,37 +1,290 @@
 package bot
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
+	"log"
 	"tg-video-bot/internal/database"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
+const (
+	defaultShutdownTimeout = 8 * time.Second
+	offsetSaveInterval     = 5 * time.Second
+	defaultCommandBurst    = 10
+	defaultCommandInterval = 2 * time.Second
+)
+
 type Bot struct {
-	API             *tgbotapi.BotAPI
-	DB              *sql.DB
-	VideoRepository database.VideoRepository
+	API   Messenger
+	Store database.Store
+	// ID — идентификатор бота в Telegram, под ним хранится прогресс апдейтов
+	ID int64
+
+	// Workers и QueueSize задают число воркеров и глубину очереди каждого из них
+	Workers   int
+	QueueSize int
+	// ShutdownTimeout ограничивает ожидание незавершенных обработчиков при остановке
+	ShutdownTimeout time.Duration
+	// AdminGroups — группы, в которых принимаются загрузки видео
+	AdminGroups []int64
+	// DialogTTL — сколько диалог ждет следующего ответа пользователя
+	DialogTTL time.Duration
+	// StripCaptionHashtags убирает хештеги из сохраняемой подписи видео
+	StripCaptionHashtags bool
+	// AlbumWindow — сколько ждать следующий файл альбома перед сохранением
+	AlbumWindow time.Duration
+	// InlineCacheTime — сколько Telegram хранит ответ на инлайн-запрос
+	InlineCacheTime time.Duration
+	// Replay — что отправлять, когда чат посмотрел все видео
+	Replay ReplayPolicy
+
+	// owners — владельцы из ADMIN_IDS, см. BootstrapOwners
+	owners   []int64
+	router   *Router
+	limiter  *commandLimiter
+	albums   *albumBuffer
+	searches *searchRegistry
+	// dispatcher — очереди чатов текущего Run, в них же сохраняются альбомы
+	dispatcher *dispatcher
+}
+
+// New создает бота поверх произвольного Messenger и хранилища
+func New(api Messenger, store database.Store) *Bot {
+	b := &Bot{
+		API:             api,
+		Store:           store,
+		Workers:         defaultWorkers,
+		QueueSize:       defaultQueueSize,
+		ShutdownTimeout: defaultShutdownTimeout,
+		DialogTTL:       defaultDialogTTL,
+		AlbumWindow:     defaultAlbumWindow,
+		InlineCacheTime: defaultInlineCacheTime,
+		Replay:          ReplayPolicy{Mode: ReplayNone, After: defaultReplayAfter},
+		limiter:         newCommandLimiter(defaultCommandBurst, defaultCommandInterval),
+		albums:          newAlbumBuffer(),
+		searches:        newSearchRegistry(),
+	}
+	b.router = b.newRouter()
+	return b
+}
+
+// SetCommandRateLimit разрешает пользователю burst команд подряд и затем
+// одну за interval. Нулевые значения отключают ограничение
+func (b *Bot) SetCommandRateLimit(burst int, interval time.Duration) {
+	b.limiter = newCommandLimiter(burst, interval)
+}
+
+// Run обрабатывает апдейты, пока канал не будет закрыт, затем ждет
+// завершения уже принятых в работу не дольше ShutdownTimeout.
+// last — update_id, до которого все было обработано к моменту запуска.
+// Прогресс периодически сохраняется в хранилище; возвращается update_id,
+// до которого включительно все апдейты обработаны
+func (b *Bot) Run(updates UpdatesChannel, last int) int {
+	return b.run(updates, newOffsetTracker(last))
+}
+
+// run — Run с трекером, который читает и источник апдейтов
+func (b *Bot) run(updates UpdatesChannel, offsets *offsetTracker) int {
+	last := offsets.Last()
+	d := newDispatcher(b.Workers, b.QueueSize, offsets, b.handleOnce)
+	b.dispatcher = d
+
+	stopSaving := make(chan struct{})
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		b.saveOffsets(offsets, last, stopSaving)
+	}()
+
+	for update := range updates {
+		d.Dispatch(update)
+	}
+
+	if !d.Close(b.ShutdownTimeout) {
+		log.Printf("Shutdown timeout exceeded, unfinished updates will be redelivered")
+	}
+	b.flushAlbums()
+	close(stopSaving)
+	<-saved
+
+	return offsets.Last()
 }
 
-func Start(token string, db *sql.DB) error {
-	botAPI, err := tgbotapi.NewBotAPI(token)
+// handleOnce пропускает апдейты, которые уже были обработаны до перезапуска
+// (Telegram присылает их снова, если offset не успели подтвердить).
+// Возвращает false для файла альбома: он будет обработан, когда альбом
+// сохранится, см. completeAlbum
+func (b *Bot) handleOnce(update Update) bool {
+	processed, err := b.Store.IsUpdateProcessed(b.ID, update.UpdateID)
+	if err != nil {
+		log.Printf("Failed to check update %d: %v", update.UpdateID, err)
+	}
+	if processed {
+		log.Printf("Skipping already processed update %d", update.UpdateID)
+		return true
+	}
+
+	b.safeHandleUpdate(update)
+	if b.albums.holds(update.UpdateID) {
+		return false
+	}
+
+	b.markProcessed(update.UpdateID)
+	return true
+}
+
+func (b *Bot) markProcessed(updateID int) {
+	if err := b.Store.MarkUpdateProcessed(b.ID, updateID); err != nil {
+		log.Printf("Failed to mark update %d as processed: %v", updateID, err)
+	}
+}
+
+// safeHandleUpdate обрабатывает апдейт, перехватывая панику в любом
+// обработчике. Упавший апдейт считается обработанным, чтобы не падать на
+// нем снова после перезапуска
+func (b *Bot) safeHandleUpdate(update Update) {
+	var chatID int64
+	switch {
+	case update.Message != nil:
+		chatID = update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		chatID = update.CallbackQuery.Message.Chat.ID
+	}
+	defer b.recoverPanic(fmt.Sprintf("update %d", update.UpdateID), chatID)
+
+	b.HandleUpdate(update)
+}
+
+// saveOffsets сохраняет продвинувшийся offset раз в offsetSaveInterval
+// и последний раз при остановке
+func (b *Bot) saveOffsets(offsets *offsetTracker, saved int, stop <-chan struct{}) {
+	ticker := time.NewTicker(offsetSaveInterval)
+	defer ticker.Stop()
+
+	save := func() {
+		last := offsets.Last()
+		if last == saved {
+			return
+		}
+		if err := b.Store.SaveUpdateOffset(b.ID, last); err != nil {
+			log.Printf("Failed to save update offset %d: %v", last, err)
+			return
+		}
+		saved = last
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			save()
+		case <-stop:
+			save()
+			return
+		}
+	}
+}
+
+// Start запускает бота и блокируется, пока ctx не будет отменен
+func Start(ctx context.Context, cfg Config, store database.Store) error {
+	botAPI, err := tgbotapi.NewBotAPI(cfg.Token)
 	if err != nil {
 		return err
 	}
 
-	bot := &Bot{
-		API:             botAPI,
-		DB:              db,
-		VideoRepository: *database.NewVideoRepository(db),
+	bot := New(NewSender(botAPI, cfg.RateLimits), store)
+	bot.ID = int64(botAPI.Self.ID)
+	bot.Workers = cfg.Workers
+	bot.QueueSize = cfg.QueueSize
+	if cfg.ShutdownTimeout > 0 {
+		bot.ShutdownTimeout = cfg.ShutdownTimeout
+	}
+	bot.SetCommandRateLimit(cfg.CommandBurst, cfg.CommandInterval)
+	bot.AdminGroups = cfg.AdminGroupIDs
+	if cfg.DialogTTL > 0 {
+		bot.DialogTTL = cfg.DialogTTL
+	}
+	bot.StripCaptionHashtags = cfg.StripCaptionHashtags
+	if cfg.AlbumWindow > 0 {
+		bot.AlbumWindow = cfg.AlbumWindow
+	}
+	if cfg.InlineCacheTime > 0 {
+		bot.InlineCacheTime = cfg.InlineCacheTime
+	}
+	if cfg.Replay.Mode != "" {
+		bot.Replay.Mode = cfg.Replay.Mode
+	}
+	if cfg.Replay.After > 0 {
+		bot.Replay.After = cfg.Replay.After
 	}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates, err := botAPI.GetUpdatesChan(u)
+	if err := bot.BootstrapOwners(cfg.OwnerIDs); err != nil {
+		return err
+	}
+	if err := bot.syncCommands(); err != nil {
+		log.Printf("Failed to sync commands with Telegram: %v", err)
+	}
 
-	for update := range updates {
-		bot.HandleUpdate(update)
+	if cfg.Webhook.URL != "" {
+		return bot.runWebhook(ctx, botAPI, cfg.Webhook)
+	}
+	return bot.runPolling(ctx, botAPI)
+}
+
+func (b *Bot) runPolling(ctx context.Context, botAPI *tgbotapi.BotAPI) error {
+	if err := deleteWebhook(botAPI); err != nil {
+		return err
+	}
+
+	last, err := b.Store.GetUpdateOffset(b.ID)
+	if err != nil {
+		return err
+	}
+	if last > 0 {
+		log.Printf("Resuming from update %d", last+1)
+	}
+
+	offsets := newOffsetTracker(last)
+	last = b.run(poll(ctx, botAPI, offsets), offsets)
+
+	if err := confirmOffset(botAPI, last); err != nil {
+		log.Printf("Failed to confirm update offset %d: %v", last, err)
 	}
 
 	return nil
 }
+
+func (b *Bot) runWebhook(ctx context.Context, botAPI *tgbotapi.BotAPI, cfg WebhookConfig) error {
+	// Offset читается до регистрации webhook: при ошибке Telegram не должен
+	// слать апдейты на сервер, который никто не обрабатывает
+	last, err := b.Store.GetUpdateOffset(b.ID)
+	if err != nil {
+		return err
+	}
+
+	wh, err := newWebhookServer(cfg)
+	if err != nil {
+		return err
+	}
+	if err := setWebhook(botAPI, cfg); err != nil {
+		return err
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- wh.ListenAndServe()
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), b.ShutdownTimeout)
+		defer cancel()
+		wh.Shutdown(shutdownCtx)
+	}()
+
+	b.Run(wh.Updates(), last)
+
+	return <-errc
+}
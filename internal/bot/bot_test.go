@@ -0,0 +1,268 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/tgtest"
+)
+
+// Участники сценариев: владелец из ADMIN_IDS, админская группа и
+// обычный пользователь в личном чате
+const (
+	testOwner      = 10
+	testAdminGroup = -100
+	testUser       = 20
+	testUserChat   = 555
+)
+
+// waitTimeout — сколько сценарий ждет ответа бота
+const waitTimeout = 3 * time.Second
+
+// newTestBot создает бота поверх поддельного Bot API и MemoryStore.
+// wrap оборачивает api, например в Sender; nil — бот отправляет напрямую
+func newTestBot(t *testing.T, wrap func(*tgbotapi.BotAPI) Messenger) (*tgtest.Server, *Bot) {
+	t.Helper()
+	srv := tgtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messenger Messenger = api
+	if wrap != nil {
+		messenger = wrap(api)
+	}
+
+	b := New(messenger, database.NewMemoryStore())
+	b.BootstrapOwners([]int64{testOwner})
+	b.AdminGroups = []int64{testAdminGroup}
+	return srv, b
+}
+
+// startPolling запускает бота на long polling до конца теста и возвращает
+// трекер его offset
+func startPolling(t *testing.T, srv *tgtest.Server, b *Bot) *offsetTracker {
+	t.Helper()
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	offsets := newOffsetTracker(0)
+	go func() {
+		defer close(done)
+		b.run(poll(ctx, api, offsets), offsets)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return offsets
+}
+
+// waitRequest ждет n-й вызов method и возвращает его
+func waitRequest(t *testing.T, srv *tgtest.Server, method string, n int) tgtest.Request {
+	t.Helper()
+	reqs, err := srv.WaitRequests(method, n, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reqs[n-1]
+}
+
+func TestUploadTagAndGetVideo(t *testing.T) {
+	srv, b := newTestBot(t, nil)
+	startPolling(t, srv, b)
+
+	srv.PushVideo(testAdminGroup, testOwner, "vid1", "кот")
+	saved := waitRequest(t, srv, "sendMessage", 1)
+	if !strings.Contains(saved.Params.Get("text"), "ID 1") {
+		t.Fatalf("upload reply = %q, want saved video ID 1", saved.Params.Get("text"))
+	}
+
+	srv.PushMessage(testAdminGroup, testOwner, "/add_tags 1 котики смешные")
+	tagged := waitRequest(t, srv, "sendMessage", 2)
+	if got, want := tagged.Params.Get("text"), "✅ Добавлены теги: котики, смешные"; got != want {
+		t.Fatalf("/add_tags reply = %q, want %q", got, want)
+	}
+
+	srv.PushMessage(testUserChat, testUser, "/get_video")
+	video := waitRequest(t, srv, "sendVideo", 1)
+	if video.ChatID() != testUserChat || video.Params.Get("video") != "vid1" {
+		t.Fatalf("sendVideo to chat %d with %q, want chat %d with vid1",
+			video.ChatID(), video.Params.Get("video"), testUserChat)
+	}
+	if markup := video.Params.Get("reply_markup"); !strings.Contains(markup, "#котики") || !strings.Contains(markup, "#смешные") {
+		t.Fatalf("sendVideo reply_markup = %s, want buttons for both tags", markup)
+	}
+}
+
+func TestUploadDialogThenTagButton(t *testing.T) {
+	srv, b := newTestBot(t, nil)
+	startPolling(t, srv, b)
+
+	// Без хештегов в подписи бот спрашивает теги в диалоге
+	srv.PushVideo(testAdminGroup, testOwner, "vid1", "")
+	prompt := waitRequest(t, srv, "sendMessage", 1)
+	if !strings.Contains(prompt.Params.Get("text"), "Отправьте теги") {
+		t.Fatalf("upload reply = %q, want tags prompt", prompt.Params.Get("text"))
+	}
+	srv.PushMessage(testAdminGroup, testOwner, "котики")
+	tagged := waitRequest(t, srv, "sendMessage", 2)
+	if !strings.Contains(tagged.Params.Get("text"), "котики") {
+		t.Fatalf("dialog reply = %q, want added tags", tagged.Params.Get("text"))
+	}
+
+	// Кнопка тега присылает видео и закрывает индикатор нажатия
+	srv.PushCallback(testUserChat, testUser, callbackTagPrefix+"котики")
+	video := waitRequest(t, srv, "sendVideo", 1)
+	if video.ChatID() != testUserChat || video.Params.Get("video") != "vid1" {
+		t.Fatalf("sendVideo to chat %d with %q, want chat %d with vid1",
+			video.ChatID(), video.Params.Get("video"), testUserChat)
+	}
+	answer := waitRequest(t, srv, "answerCallbackQuery", 1)
+	if answer.Params.Get("callback_query_id") == "" {
+		t.Fatalf("answerCallbackQuery without callback_query_id: %v", answer.Params)
+	}
+
+	// Все видео с тегом уже отправлены в чат
+	srv.PushCallback(testUserChat, testUser, callbackTagPrefix+"котики")
+	waitRequest(t, srv, "answerCallbackQuery", 2)
+	if reqs := srv.Requests("sendVideo"); len(reqs) != 1 {
+		t.Fatalf("got %d sendVideo after all videos were sent, want 1", len(reqs))
+	}
+}
+
+func TestWebhookSecretToken(t *testing.T) {
+	srv, b := newTestBot(t, nil)
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := WebhookConfig{URL: "https://example.com/hook", SecretToken: "s3cret"}
+	if err := setWebhook(api, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := srv.Requests("setWebhook"); len(reqs) != 1 || reqs[0].Params.Get("secret_token") != cfg.SecretToken {
+		t.Fatalf("setWebhook requests = %v, want one with secret_token", reqs)
+	}
+
+	wh, err := newWebhookServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Обработчик слушает случайный порт вместо cfg.Listen
+	hook := httptest.NewServer(wh.server.Handler)
+	defer hook.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Run(wh.Updates(), 0)
+	}()
+	defer func() {
+		if err := wh.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+		<-done
+	}()
+
+	post := func(id int, secret string) int {
+		t.Helper()
+		body := `{"update_id":` + strconv.Itoa(id) + `,"message":{"message_id":1,"date":1,"text":"/start",` +
+			`"entities":[{"type":"bot_command","offset":0,"length":6}],` +
+			`"chat":{"id":` + strconv.Itoa(testUserChat) + `,"type":"private"},"from":{"id":` + strconv.Itoa(testUser) + `}}}`
+		req, err := http.NewRequest(http.MethodPost, hook.URL+"/hook", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret != "" {
+			req.Header.Set(secretTokenHeader, secret)
+		}
+		resp, err := hook.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, secret := range []string{"", "wrong"} {
+		if code := post(1, secret); code != http.StatusUnauthorized {
+			t.Fatalf("secret %q: status %d, want %d", secret, code, http.StatusUnauthorized)
+		}
+	}
+	if code := post(2, cfg.SecretToken); code != http.StatusOK {
+		t.Fatalf("valid secret: status %d, want %d", code, http.StatusOK)
+	}
+
+	reply := waitRequest(t, srv, "sendMessage", 1)
+	if reply.ChatID() != testUserChat {
+		t.Fatalf("reply sent to chat %d, want %d", reply.ChatID(), testUserChat)
+	}
+	// Отклоненный апдейт до бота не дошел
+	time.Sleep(100 * time.Millisecond)
+	if reqs := srv.Requests("sendMessage"); len(reqs) != 1 {
+		t.Fatalf("got %d replies, want 1", len(reqs))
+	}
+}
+
+func TestSenderRetriesAfterTooManyRequests(t *testing.T) {
+	srv, b := newTestBot(t, func(api *tgbotapi.BotAPI) Messenger {
+		return NewSender(api, DefaultRateLimits)
+	})
+	startPolling(t, srv, b)
+
+	srv.FailNext("sendMessage", 1)
+	start := time.Now()
+	srv.PushMessage(testUserChat, testUser, "/start")
+
+	reply := waitRequest(t, srv, "sendMessage", 1)
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("reply sent after %v, want retry no earlier than retry_after", elapsed)
+	}
+	if reply.ChatID() != testUserChat {
+		t.Fatalf("reply sent to chat %d, want %d", reply.ChatID(), testUserChat)
+	}
+}
+
+// failingOffsetStore — хранилище, которое не может прочитать offset
+type failingOffsetStore struct {
+	*database.MemoryStore
+}
+
+func (failingOffsetStore) GetUpdateOffset(botID int64) (int, error) {
+	return 0, errors.New("offset unavailable")
+}
+
+func TestRunWebhookOffsetError(t *testing.T) {
+	srv := tgtest.NewServer()
+	defer srv.Close()
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New(api, failingOffsetStore{database.NewMemoryStore()})
+
+	cfg := WebhookConfig{URL: "https://example.com/hook", Listen: "127.0.0.1:0"}
+	if err := b.runWebhook(context.Background(), api, cfg); err == nil {
+		t.Fatal("runWebhook succeeded without an offset")
+	}
+	if reqs := srv.Requests("setWebhook"); len(reqs) != 0 {
+		t.Fatalf("webhook was registered although the bot did not start: %v", reqs)
+	}
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Данные кнопок карусели
+const (
+	callbackCarouselPrefix = "car:"
+	// callbackNoop — кнопка-счетчик страниц, нажатие на нее ничего не делает
+	callbackNoop = "noop"
+)
+
+// errNotEditable — файл нельзя показать через editMessageMedia
+var errNotEditable = errors.New("media type cannot be edited")
+
+// carouselPage — данные кнопки листания: запрос из searchRegistry,
+// страница, на которую ведет кнопка, и курсор — ID видео, показанного
+// в сообщении сейчас
+type carouselPage struct {
+	search string
+	page   int
+	cursor int64
+}
+
+func (p carouselPage) data() string {
+	return fmt.Sprintf("%s%s:%d:%d", callbackCarouselPrefix, p.search, p.page, p.cursor)
+}
+
+// parseCarouselPage разбирает данные кнопки вида car:<запрос>:<страница>:<курсор>
+func parseCarouselPage(data string) (carouselPage, bool) {
+	parts := strings.Split(strings.TrimPrefix(data, callbackCarouselPrefix), ":")
+	if len(parts) != 3 {
+		return carouselPage{}, false
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil || page < 0 {
+		return carouselPage{}, false
+	}
+	cursor, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return carouselPage{}, false
+	}
+	return carouselPage{search: parts[0], page: page, cursor: cursor}, true
+}
+
+// carouselKeyboard строит ряд ◀️ N / M ▶️ для страницы page из total
+func carouselKeyboard(search string, page, total int, cursor int64) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		prev := carouselPage{search: search, page: page - 1, cursor: cursor}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️", prev.data()))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d / %d", page+1, total), callbackNoop))
+	if page < total-1 {
+		next := carouselPage{search: search, page: page + 1, cursor: cursor}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶️", next.data()))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// startCarousel отправляет первое видео по запросу с кнопками листания.
+// Возвращает false, если ничего не найдено
+func (b *Bot) startCarousel(chatID int64, query tagquery.Expr) (bool, error) {
+	videos, total, err := b.Store.FindVideos(query, database.FindOptions{ChatID: chatID, Limit: 1})
+	if err != nil || len(videos) == 0 {
+		return false, err
+	}
+	search := b.searches.save(query, false)
+
+	v := videos[0]
+	if err := b.sendMedia(chatID, v, carouselKeyboard(search, 0, total, v.ID)); err != nil {
+		return true, err
+	}
+	b.markDelivered(chatID, []int64{v.ID}, models.SourceTag)
+	return true, nil
+}
+
+// HandleCarouselCallback листает карусель, заменяя файл в том же сообщении.
+// Если запрос устарел или результатов больше нет, кнопки убираются
+func (b *Bot) HandleCarouselCallback(query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	p, ok := parseCarouselPage(query.Data)
+	if !ok {
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	search, ok := b.searches.get(p.search)
+	if !ok {
+		b.removeKeyboard(chatID, messageID)
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "Страница устарела, повторите поиск"))
+		return
+	}
+
+	opts := database.FindOptions{ChatID: chatID, Limit: 1, Offset: p.page}
+	videos, total, err := b.Store.FindVideos(search.query, opts)
+	if err == nil && total > 0 && len(videos) == 0 {
+		// Часть результатов удалили, показываем последнюю страницу
+		p.page = total - 1
+		opts.Offset = p.page
+		videos, total, err = b.Store.FindVideos(search.query, opts)
+	}
+	if err != nil {
+		log.Printf("Failed to find videos by %q: %v", search.query, err)
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "❌ Ошибка поиска"))
+		return
+	}
+	if len(videos) == 0 {
+		b.removeKeyboard(chatID, messageID)
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "Видео больше нет"))
+		return
+	}
+
+	v := videos[0]
+	if v.ID == p.cursor {
+		// Повторное нажатие: это видео уже показано
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+
+	markup := carouselKeyboard(p.search, p.page, total, v.ID)
+	if err := b.editMedia(chatID, messageID, v, markup); err != nil {
+		// Кружки нельзя подставить в сообщение, отправляем новым
+		log.Printf("Failed to edit carousel in chat %d: %v", chatID, err)
+		b.removeKeyboard(chatID, messageID)
+		if err := b.sendMedia(chatID, v, markup); err != nil {
+			log.Printf("Failed to send video %d: %v", v.ID, err)
+			b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "❌ Не удалось отправить видео"))
+			return
+		}
+	}
+
+	b.markDelivered(chatID, []int64{v.ID}, models.SourceTag)
+	b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+}
+
+// editMedia заменяет файл, подпись и кнопки сообщения. В tgbotapi v4 нет
+// editMessageMedia, поэтому запрос идет через MakeRequest
+func (b *Bot) editMedia(chatID int64, messageID int, v models.Video, markup tgbotapi.InlineKeyboardMarkup) error {
+	if v.Type == models.MediaVideoNote {
+		return errNotEditable
+	}
+
+	item := inputMedia{Type: string(v.Type), Media: v.FileID, Caption: v.Caption}
+	if item.Type == "" {
+		item.Type = string(models.MediaVideo)
+	}
+	media, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	keyboard, err := json.Marshal(markup)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	params.Set("message_id", strconv.Itoa(messageID))
+	params.Set("media", string(media))
+	params.Set("reply_markup", string(keyboard))
+
+	_, err = b.API.MakeRequest("editMessageMedia", params)
+	return err
+}
+
+// removeKeyboard убирает инлайн-кнопки с сообщения
+func (b *Bot) removeKeyboard(chatID int64, messageID int) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := b.API.Send(edit); err != nil {
+		log.Printf("Failed to remove buttons in chat %d: %v", chatID, err)
+	}
+}
@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"encoding/json"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"tg-video-bot/internal/models"
+)
+
+// TestCarouselMarksVideosSent проверяет, что видео, показанные в карусели,
+// и первое, и пролистанное, больше не считаются неотправленными
+func TestCarouselMarksVideosSent(t *testing.T) {
+	srv, b := newTestBot(t, nil)
+	for _, fileID := range []string{"vid1", "vid2"} {
+		if _, err := b.Store.SaveVideo(models.Video{FileID: fileID, FileUniqueID: "u-" + fileID, Tags: []string{"котики"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	startPolling(t, srv, b)
+
+	srv.PushMessage(testUserChat, testUser, "/get_by_tag котики")
+	first := waitRequest(t, srv, "sendVideo", 1)
+	next := carouselButton(t, first.Params.Get("reply_markup"), "▶️")
+
+	srv.PushCallback(testUserChat, testUser, next)
+	waitRequest(t, srv, "editMessageMedia", 1)
+	waitRequest(t, srv, "answerCallbackQuery", 1)
+
+	unsent, err := b.Store.GetRandomUnsentVideo(testUserChat, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsent) != 0 {
+		t.Fatalf("got %d unsent videos after the carousel showed both, want 0", len(unsent))
+	}
+}
+
+// carouselButton возвращает данные кнопки text из разметки reply_markup
+func carouselButton(t *testing.T, markup, text string) string {
+	t.Helper()
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(markup), &keyboard); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == text && button.CallbackData != nil {
+				return *button.CallbackData
+			}
+		}
+	}
+	t.Fatalf("no %q button in %s", text, markup)
+	return ""
+}
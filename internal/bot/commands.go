@@ -0,0 +1,288 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// newRouter регистрирует все команды бота
+func (b *Bot) newRouter() *Router {
+	r := NewRouter(b.HandleUnknownCommand)
+	r.Use(b.logMiddleware, b.rateLimitMiddleware, b.authMiddleware)
+
+	r.Handle(Command{
+		Name:        "start",
+		Description: "Показать меню",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleStartCommand,
+	})
+	r.Handle(Command{
+		Name:        "help",
+		Description: "Список команд",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleHelpCommand,
+	})
+	r.Handle(Command{
+		Name:        "get_video",
+		Description: "Получить случайное новое видео",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleGetVideoCommand,
+	})
+	r.Handle(Command{
+		Name:        "get_videos",
+		Args:        "[количество]",
+		Description: "Получить несколько случайных новых видео",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleGetVideosCommand,
+	})
+	r.Handle(Command{
+		Name:        "random",
+		Args:        "[запрос]",
+		Description: "Случайное новое видео по тегам",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleRandomCommand,
+	})
+	r.Handle(Command{
+		Name:        "get_by_tag",
+		Args:        "[тег]",
+		Description: "Найти видео по тегу",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleGetByTagCommand,
+	})
+	r.Handle(Command{
+		Name:        "find",
+		Args:        "[--new] [запрос]",
+		Description: "Поиск по тегам: кот -собака (смешные | мемы) муз*",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleFindCommand,
+	})
+	r.Handle(Command{
+		Name:        "reset_history",
+		Description: "Сбросить историю просмотров",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleResetHistoryCommand,
+	})
+	r.Handle(Command{
+		Name:        "cancel",
+		Description: "Отменить текущее действие",
+		Role:        models.RoleViewer,
+		Handler:     b.HandleCancelCommand,
+	})
+	r.Handle(Command{
+		Name:        "add_video",
+		Description: "Добавить видео в базу",
+		Role:        models.RoleUploader,
+		Handler:     b.HandleAddVideoCommand,
+	})
+	r.Handle(Command{
+		Name:        "add_tags",
+		Args:        "[ID] [теги]",
+		Description: "Добавить теги к видео",
+		Role:        models.RoleUploader,
+		Handler:     b.HandleAddTagsCommand,
+	})
+	r.Handle(Command{
+		Name:        "list_videos",
+		Description: "Список всех видео",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleListVideosCommand,
+	})
+	r.Handle(Command{
+		Name:        "video_info",
+		Args:        "[ID]",
+		Description: "Подробности о видео",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleVideoInfoCommand,
+	})
+	r.Handle(Command{
+		Name:        "delete_video",
+		Args:        "[ID]",
+		Description: "Удалить видео",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleDeleteVideoCommand,
+	})
+	r.Handle(Command{
+		Name:        "remove_tags",
+		Args:        "[ID] [теги]",
+		Description: "Снять теги с видео",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleRemoveTagsCommand,
+	})
+	r.Handle(Command{
+		Name:        "tags",
+		Args:        "[страница]",
+		Description: "Все теги с числом видео",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleTagsCommand,
+	})
+	r.Handle(Command{
+		Name:        "rename_tag",
+		Args:        "[старый] [новый]",
+		Description: "Переименовать тег",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleRenameTagCommand,
+	})
+	r.Handle(Command{
+		Name:        "merge_tags",
+		Args:        "[теги...] [куда]",
+		Description: "Объединить теги в один",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleMergeTagsCommand,
+	})
+	r.Handle(Command{
+		Name:        "delete_tag",
+		Args:        "[тег]",
+		Description: "Удалить тег со всех видео",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleDeleteTagCommand,
+	})
+	r.Handle(Command{
+		Name:        "aliases",
+		Description: "Псевдонимы тегов",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleAliasesCommand,
+	})
+	r.Handle(Command{
+		Name:        "alias",
+		Args:        "[псевдонимы...] [тег]",
+		Description: "Добавить псевдонимы тега: кошки cats → котики",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleAliasCommand,
+	})
+	r.Handle(Command{
+		Name:        "unalias",
+		Args:        "[псевдонимы...]",
+		Description: "Удалить псевдонимы",
+		Role:        models.RoleModerator,
+		Handler:     b.HandleUnaliasCommand,
+	})
+	r.Handle(Command{
+		Name:        "stats",
+		Args:        "[day|week|month|all]",
+		Description: "Статистика использования",
+		Role:        models.RoleAdmin,
+		Handler:     b.HandleStatsCommand,
+	})
+	r.Handle(Command{
+		Name:        "reset_chat",
+		Args:        "[ID чата]",
+		Description: "Сбросить историю просмотров чата",
+		Role:        models.RoleAdmin,
+		Handler:     b.HandleResetChatCommand,
+	})
+	r.Handle(Command{
+		Name:        "grant",
+		Args:        "[ID пользователя] [роль]",
+		Description: "Выдать роль",
+		Role:        models.RoleOwner,
+		Handler:     b.HandleGrantCommand,
+	})
+	r.Handle(Command{
+		Name:        "revoke",
+		Args:        "[ID пользователя]",
+		Description: "Сбросить роль до viewer",
+		Role:        models.RoleOwner,
+		Handler:     b.HandleRevokeCommand,
+	})
+
+	return r
+}
+
+// botCommand — элемент списка команд для setMyCommands
+type botCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// syncCommands публикует список команд в Telegram: команды viewer для всех
+// и расширенные списки в личных чатах пользователей с выданными ролями
+func (b *Bot) syncCommands() error {
+	if err := setMyCommands(b.API, b.router.Commands(models.RoleViewer), nil); err != nil {
+		return err
+	}
+
+	users, err := b.Store.ListUserRoles()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := b.syncUserCommands(u.UserID, u.Role); err != nil {
+			log.Printf("Failed to set commands for user %d: %v", u.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+// syncUserCommands обновляет меню команд в личном чате пользователя под его роль
+func (b *Bot) syncUserCommands(userID int64, role models.Role) error {
+	scope := map[string]interface{}{"type": "chat", "chat_id": userID}
+	if role == models.RoleViewer {
+		return deleteMyCommands(b.API, scope)
+	}
+	return setMyCommands(b.API, b.router.Commands(role), scope)
+}
+
+func setMyCommands(api Messenger, cmds []*Command, scope interface{}) error {
+	list := make([]botCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		list = append(list, botCommand{Command: cmd.Name, Description: cmd.Description})
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Add("commands", string(data))
+	if scope != nil {
+		scopeData, err := json.Marshal(scope)
+		if err != nil {
+			return err
+		}
+		v.Add("scope", string(scopeData))
+	}
+
+	if _, err := api.MakeRequest("setMyCommands", v); err != nil {
+		return fmt.Errorf("setMyCommands failed: %v", err)
+	}
+	return nil
+}
+
+func deleteMyCommands(api Messenger, scope interface{}) error {
+	scopeData, err := json.Marshal(scope)
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Add("scope", string(scopeData))
+	if _, err := api.MakeRequest("deleteMyCommands", v); err != nil {
+		return fmt.Errorf("deleteMyCommands failed: %v", err)
+	}
+	return nil
+}
+
+// HandleStartCommand обрабатывает команду /start
+func (b *Bot) HandleStartCommand(msg *tgbotapi.Message) {
+	b.ShowMainMenu(msg.Chat.ID)
+	if role := b.userRole(msg); role.AtLeast(models.RoleModerator) && msg.Text == "⚙️ Админ-панель" {
+		b.ShowAdminMenu(msg.Chat.ID, role)
+	}
+}
+
+// HandleHelpCommand отправляет список команд, доступных пользователю
+func (b *Bot) HandleHelpCommand(msg *tgbotapi.Message) {
+	b.SendMessage(msg.Chat.ID, b.router.HelpText(b.userRole(msg)))
+}
+
+// HandleUnknownCommand отвечает на команду, которой нет в роутере
+func (b *Bot) HandleUnknownCommand(msg *tgbotapi.Message) {
+	b.SendUnknownCommand(msg.Chat.ID)
+}
@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config содержит настройки запуска бота
+type Config struct {
+	Token   string
+	Webhook WebhookConfig
+
+	// Workers — число параллельных обработчиков апдейтов
+	Workers int
+	// QueueSize — глубина очереди каждого обработчика
+	QueueSize int
+	// ShutdownTimeout — сколько ждать незавершенные обработчики при остановке
+	ShutdownTimeout time.Duration
+
+	RateLimits RateLimits
+
+	// CommandBurst и CommandInterval ограничивают частоту команд от пользователя
+	CommandBurst    int
+	CommandInterval time.Duration
+
+	// OwnerIDs получают роль owner при первом запуске, если у них еще нет
+	// роли в базе. Дальше роли управляются только командами /grant и /revoke
+	OwnerIDs []int64
+	// AdminGroupIDs — чаты, в которых принимаются загрузки видео помимо личных
+	AdminGroupIDs []int64
+
+	// DialogTTL — время жизни незавершенного диалога
+	DialogTTL time.Duration
+	// StripCaptionHashtags убирает хештеги из подписи после переноса в теги
+	StripCaptionHashtags bool
+	// AlbumWindow — сколько ждать остальные файлы альбома
+	AlbumWindow time.Duration
+	// InlineCacheTime — сколько Telegram хранит ответ на инлайн-запрос
+	InlineCacheTime time.Duration
+	// Replay — повторы, когда чат посмотрел все видео
+	Replay ReplayPolicy
+}
+
+// WebhookConfig описывает режим webhook. Если URL пуст, бот работает
+// через long polling
+type WebhookConfig struct {
+	// URL — публичный адрес, который получит Telegram в setWebhook
+	URL string
+	// Listen — адрес локального HTTP-сервера, по умолчанию ":8443"
+	Listen string
+	// SecretToken сверяется с заголовком X-Telegram-Bot-Api-Secret-Token
+	SecretToken string
+	// TLSCert и TLSKey включают TLS на локальном сервере
+	TLSCert string
+	TLSKey  string
+	// UploadCert загружает TLSCert в setWebhook для самоподписанного сертификата
+	UploadCert bool
+}
+
+// LoadConfig читает настройки из переменных окружения
+func LoadConfig() Config {
+	return Config{
+		Token: os.Getenv("TELEGRAM_BOT_TOKEN"),
+		Webhook: WebhookConfig{
+			URL:         os.Getenv("WEBHOOK_URL"),
+			Listen:      envOrDefault("WEBHOOK_LISTEN", ":8443"),
+			SecretToken: os.Getenv("WEBHOOK_SECRET"),
+			TLSCert:     os.Getenv("WEBHOOK_TLS_CERT"),
+			TLSKey:      os.Getenv("WEBHOOK_TLS_KEY"),
+			UploadCert:  os.Getenv("WEBHOOK_SELF_SIGNED") == "true",
+		},
+		Workers:         envInt("BOT_WORKERS", defaultWorkers),
+		QueueSize:       envInt("BOT_QUEUE_SIZE", defaultQueueSize),
+		ShutdownTimeout: envDuration("BOT_SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		RateLimits: RateLimits{
+			Global:         envInt("SEND_GLOBAL_RATE", DefaultRateLimits.Global),
+			ChatInterval:   envDuration("SEND_CHAT_INTERVAL", DefaultRateLimits.ChatInterval),
+			GroupPerMinute: envInt("SEND_GROUP_PER_MINUTE", DefaultRateLimits.GroupPerMinute),
+		},
+		CommandBurst:    envInt("COMMAND_BURST", defaultCommandBurst),
+		CommandInterval: envDuration("COMMAND_INTERVAL", defaultCommandInterval),
+		OwnerIDs:        parseIDs(os.Getenv("ADMIN_IDS")),
+		AdminGroupIDs:   parseIDs(os.Getenv("ADMIN_GROUP_IDS")),
+		DialogTTL:       envDuration("DIALOG_TTL", defaultDialogTTL),
+
+		StripCaptionHashtags: os.Getenv("STRIP_CAPTION_HASHTAGS") == "true",
+		AlbumWindow:          envDuration("ALBUM_WINDOW", defaultAlbumWindow),
+		InlineCacheTime:      envDuration("INLINE_CACHE_TIME", defaultInlineCacheTime),
+		Replay: ReplayPolicy{
+			Mode:  envReplayMode("REPLAY_POLICY"),
+			After: time.Duration(envInt("REPLAY_AFTER_DAYS", 0)) * 24 * time.Hour,
+		},
+	}
+}
+
+// envReplayMode читает режим повторов; неизвестное значение выключает повторы
+func envReplayMode(key string) ReplayMode {
+	v := os.Getenv(key)
+	if v == "" {
+		return ReplayNone
+	}
+	mode, ok := ParseReplayMode(v)
+	if !ok {
+		log.Printf("Unknown %s %q, replays are disabled", key, v)
+		return ReplayNone
+	}
+	return mode
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,304 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const defaultDialogTTL = 10 * time.Minute
+
+// Шаги диалогов. Префикс до двоеточия — имя сценария
+const (
+	stateAddTagsVideo   = "add_tags:video"
+	stateAddTagsTags    = "add_tags:tags"
+	stateAddTagsConfirm = "add_tags:confirm"
+	stateUploadTags     = "upload:tags"
+	stateMergeConfirm   = "merge:confirm"
+	stateDeleteVideo    = "delete:video"
+	stateDeleteConfirm  = "delete:confirm"
+	// Сброс истории просмотров: своего чата и любого чата админом
+	stateResetConfirm     = "reset:confirm"
+	stateResetChatConfirm = "reset_chat:confirm"
+	// Управление тегами
+	stateMergeTagsConfirm = "merge_tags:confirm"
+	stateDeleteTagConfirm = "delete_tag:confirm"
+)
+
+// Данные кнопок подтверждения
+const (
+	callbackDialogYes = "dialog_yes"
+	callbackDialogNo  = "dialog_no"
+)
+
+// dialogRoles — минимальная роль для каждого шага. Проверяется на каждом
+// шаге, чтобы отозванная посреди диалога роль сразу переставала действовать
+var dialogRoles = map[string]models.Role{
+	stateAddTagsVideo:     models.RoleUploader,
+	stateAddTagsTags:      models.RoleUploader,
+	stateAddTagsConfirm:   models.RoleUploader,
+	stateUploadTags:       models.RoleUploader,
+	stateMergeConfirm:     models.RoleUploader,
+	stateDeleteVideo:      models.RoleModerator,
+	stateDeleteConfirm:    models.RoleModerator,
+	stateResetConfirm:     models.RoleViewer,
+	stateResetChatConfirm: models.RoleAdmin,
+	stateMergeTagsConfirm: models.RoleModerator,
+	stateDeleteTagConfirm: models.RoleModerator,
+}
+
+// startDialog переводит чат на шаг state и отправляет подсказку
+func (b *Bot) startDialog(msg *tgbotapi.Message, state string, data map[string]string, prompt string, markup interface{}) {
+	if data == nil {
+		data = make(map[string]string)
+	}
+
+	err := b.Store.SaveChatState(models.ChatState{
+		ChatID:    msg.Chat.ID,
+		UserID:    senderID(msg),
+		State:     state,
+		Data:      data,
+		ExpiresAt: time.Now().Add(b.DialogTTL),
+	})
+	if err != nil {
+		log.Printf("Failed to save state of chat %d: %v", msg.Chat.ID, err)
+		b.SendMessage(msg.Chat.ID, "❌ Не удалось начать диалог")
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, prompt)
+	if markup != nil {
+		reply.ReplyMarkup = markup
+	}
+	if _, err := b.API.Send(reply); err != nil {
+		log.Printf("Failed to send dialog prompt to chat %d: %v", msg.Chat.ID, err)
+	}
+}
+
+// endDialog сбрасывает состояние чата
+func (b *Bot) endDialog(chatID int64) {
+	if err := b.Store.DeleteChatState(chatID); err != nil {
+		log.Printf("Failed to delete state of chat %d: %v", chatID, err)
+	}
+}
+
+// activeDialog возвращает состояние диалога, который ведет userID в чате.
+// Истекшее состояние удаляется, и автору сообщается, что диалог устарел
+func (b *Bot) activeDialog(chatID, userID int64) (models.ChatState, bool) {
+	state, ok, err := b.Store.GetChatState(chatID)
+	if err != nil {
+		log.Printf("Failed to get state of chat %d: %v", chatID, err)
+		return state, false
+	}
+	if !ok || state.UserID != userID {
+		return state, false
+	}
+
+	if state.Expired(time.Now()) {
+		b.endDialog(chatID)
+		b.SendMessage(chatID, "⌛ Время ожидания истекло, начните заново")
+		return state, false
+	}
+
+	if !b.can(userID, dialogRoles[state.State]) {
+		b.endDialog(chatID)
+		b.SendMessage(chatID, "❌ Недостаточно прав")
+		return state, false
+	}
+
+	return state, true
+}
+
+// HandleDialogMessage передает текст текущему шагу диалога.
+// Возвращает false, если в чате нет диалога этого пользователя
+func (b *Bot) HandleDialogMessage(msg *tgbotapi.Message) bool {
+	state, ok := b.activeDialog(msg.Chat.ID, senderID(msg))
+	if !ok {
+		return false
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	switch state.State {
+	case stateAddTagsVideo:
+		b.dialogPickVideo(msg, text, stateAddTagsTags, "Введите теги через пробел:")
+
+	case stateAddTagsTags:
+		tags := strings.Fields(text)
+		if len(tags) == 0 {
+			b.SendMessage(msg.Chat.ID, "Введите хотя бы один тег или /cancel")
+			return true
+		}
+		state.Data["tags"] = strings.Join(tags, " ")
+		b.startDialog(msg, stateAddTagsConfirm, state.Data,
+			fmt.Sprintf("Добавить к видео %s теги: %s?", state.Data["video_id"], strings.Join(tags, ", ")),
+			confirmKeyboard())
+
+	case stateUploadTags:
+		tags := strings.Fields(text)
+		if len(tags) == 0 {
+			b.SendMessage(msg.Chat.ID, "Введите хотя бы один тег или /cancel")
+			return true
+		}
+		b.endDialog(msg.Chat.ID)
+		if albumID := state.Data["album_id"]; albumID != "" {
+			b.addAlbumTags(msg.Chat.ID, albumID, tags)
+		} else {
+			b.addTags(msg.Chat.ID, state.Data["video_id"], tags)
+		}
+
+	case stateDeleteVideo:
+		b.dialogPickVideo(msg, text, stateDeleteConfirm, "")
+
+	case stateAddTagsConfirm, stateMergeConfirm, stateDeleteConfirm, stateResetConfirm, stateResetChatConfirm,
+		stateMergeTagsConfirm, stateDeleteTagConfirm:
+		switch strings.ToLower(text) {
+		case "да", "yes", "✅ да":
+			b.finishDialog(msg.Chat.ID, state)
+		case "нет", "no", "❌ нет":
+			b.endDialog(msg.Chat.ID)
+			b.SendMessage(msg.Chat.ID, "❌ Действие отменено")
+		default:
+			b.SendMessage(msg.Chat.ID, "Ответьте «да» или «нет», либо нажмите кнопку")
+		}
+
+	default:
+		// Неизвестный шаг мог остаться от прошлой версии бота
+		b.endDialog(msg.Chat.ID)
+		return false
+	}
+
+	return true
+}
+
+// dialogPickVideo разбирает ID видео и переводит диалог на шаг next.
+// Для шага подтверждения удаления подсказка строится по самому видео
+func (b *Bot) dialogPickVideo(msg *tgbotapi.Message, text, next, prompt string) {
+	videoID, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		b.SendMessage(msg.Chat.ID, "❌ Неверный ID видео, попробуйте еще раз или /cancel")
+		return
+	}
+
+	exists, err := b.Store.VideoExists(videoID)
+	if err != nil {
+		log.Printf("Failed to check video %d: %v", videoID, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка проверки видео")
+		return
+	}
+	if !exists {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Видео с ID %d не найдено, попробуйте еще раз или /cancel", videoID))
+		return
+	}
+
+	data := map[string]string{"video_id": strconv.FormatInt(videoID, 10)}
+	if next == stateDeleteConfirm {
+		b.startDialog(msg, next, data, fmt.Sprintf("Удалить видео ID %d?", videoID), confirmKeyboard())
+		return
+	}
+	b.startDialog(msg, next, data, prompt, nil)
+}
+
+// finishDialog выполняет подтвержденное действие и завершает диалог
+func (b *Bot) finishDialog(chatID int64, state models.ChatState) {
+	b.endDialog(chatID)
+
+	switch state.State {
+	case stateAddTagsConfirm, stateMergeConfirm:
+		b.addTags(chatID, state.Data["video_id"], strings.Fields(state.Data["tags"]))
+
+	case stateDeleteConfirm:
+		videoID, _ := strconv.ParseInt(state.Data["video_id"], 10, 64)
+		if err := b.Store.DeleteVideo(videoID); err != nil {
+			log.Printf("Failed to delete video %d: %v", videoID, err)
+			b.SendMessage(chatID, "❌ Ошибка удаления видео")
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("✅ Видео ID %d удалено", videoID))
+
+	case stateResetConfirm, stateResetChatConfirm:
+		b.resetHistory(chatID, state.Data["chat_id"])
+
+	case stateMergeTagsConfirm:
+		b.mergeTags(chatID, strings.Fields(state.Data["from"]), state.Data["into"])
+
+	case stateDeleteTagConfirm:
+		b.deleteTag(chatID, state.Data["tag"])
+	}
+}
+
+// addTags добавляет теги к видео и сообщает результат
+func (b *Bot) addTags(chatID int64, videoIDStr string, tags []string) {
+	videoID, err := strconv.ParseInt(videoIDStr, 10, 64)
+	if err != nil {
+		b.SendMessage(chatID, "❌ Неверный ID видео")
+		return
+	}
+
+	if err := b.Store.AddTagsToVideo(videoID, tags); err != nil {
+		log.Printf("Ошибка добавления тегов: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка добавления тегов")
+		return
+	}
+
+	b.SendMessage(chatID, fmt.Sprintf("✅ Добавлены теги: %s", strings.Join(tags, ", ")))
+}
+
+// HandleDialogCallback обрабатывает кнопки подтверждения диалога
+func (b *Bot) HandleDialogCallback(query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	state, ok := b.activeDialog(chatID, int64(query.From.ID))
+	if !ok || !isConfirmState(state.State) {
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "Диалог уже завершен"))
+		return
+	}
+
+	// Убираем кнопки, чтобы подтверждение нельзя было нажать повторно
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := b.API.Send(edit); err != nil {
+		log.Printf("Failed to remove confirmation buttons: %v", err)
+	}
+
+	if query.Data == callbackDialogYes {
+		b.finishDialog(chatID, state)
+	} else {
+		b.endDialog(chatID)
+		b.SendMessage(chatID, "❌ Действие отменено")
+	}
+	b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+}
+
+// HandleCancelCommand обрабатывает /cancel
+func (b *Bot) HandleCancelCommand(msg *tgbotapi.Message) {
+	state, ok, err := b.Store.GetChatState(msg.Chat.ID)
+	if err != nil {
+		log.Printf("Failed to get state of chat %d: %v", msg.Chat.ID, err)
+	}
+	if !ok || state.UserID != senderID(msg) {
+		b.SendMessage(msg.Chat.ID, "Нечего отменять")
+		return
+	}
+
+	b.endDialog(msg.Chat.ID)
+	b.SendMessage(msg.Chat.ID, "❌ Действие отменено")
+}
+
+// isConfirmState проверяет, что шаг ждет ответа «да» или «нет»
+func isConfirmState(state string) bool {
+	return strings.HasSuffix(state, ":confirm")
+}
+
+func confirmKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да", callbackDialogYes),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Нет", callbackDialogNo),
+		),
+	)
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	defaultWorkers   = 8
+	defaultQueueSize = 100
+)
+
+// dispatcher обрабатывает апдейты параллельно в нескольких воркерах.
+// Апдейты одного чата всегда попадают в один и тот же воркер, поэтому
+// внутри чата порядок сохраняется. handle возвращает false, если апдейт
+// отложен и будет завершен позже через offsets.Done
+type dispatcher struct {
+	handle  func(Update) bool
+	shards  []chan job
+	wg      sync.WaitGroup
+	offsets *offsetTracker
+
+	// mu не дает Submit писать в закрытые очереди
+	mu     sync.RWMutex
+	closed bool
+}
+
+// job — апдейт или задача, которую нужно выполнить в воркере чата
+type job struct {
+	update Update
+	task   func()
+}
+
+func newDispatcher(workers, queueSize int, offsets *offsetTracker, handle func(Update) bool) *dispatcher {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	d := &dispatcher{
+		handle:  handle,
+		shards:  make([]chan job, workers),
+		offsets: offsets,
+	}
+	for i := range d.shards {
+		d.shards[i] = make(chan job, queueSize)
+		d.wg.Add(1)
+		go d.work(d.shards[i])
+	}
+
+	return d
+}
+
+// Dispatch ставит апдейт в очередь его чата. Если очередь заполнена,
+// вызов блокируется, пока воркер ее не разгрузит: так чтение новых
+// апдейтов притормаживает вместе с обработкой
+func (d *dispatcher) Dispatch(update Update) {
+	shard := d.shards[shardIndex(updateChatID(update.Update), len(d.shards))]
+	d.offsets.Start(update.UpdateID)
+
+	select {
+	case shard <- job{update: update}:
+	default:
+		log.Printf("Update queue is full, waiting (update %d)", update.UpdateID)
+		shard <- job{update: update}
+	}
+}
+
+// Submit ставит задачу в очередь чата chatID, после уже поставленных туда
+// апдейтов. Возвращает false, если диспетчер уже закрыт
+func (d *dispatcher) Submit(chatID int64, task func()) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return false
+	}
+	d.shards[shardIndex(chatID, len(d.shards))] <- job{task: task}
+	return true
+}
+
+// Close перестает принимать апдейты и ждет обработки уже поставленных
+// в очередь не дольше timeout. Возвращает false, если не дождался
+func (d *dispatcher) Close(timeout time.Duration) bool {
+	d.mu.Lock()
+	d.closed = true
+	for _, shard := range d.shards {
+		close(shard)
+	}
+	d.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (d *dispatcher) work(jobs <-chan job) {
+	defer d.wg.Done()
+	for j := range jobs {
+		if j.task != nil {
+			j.task()
+			continue
+		}
+		if d.handle(j.update) {
+			d.offsets.Done(j.update.UpdateID)
+		} else {
+			d.offsets.Hold(j.update.UpdateID)
+		}
+	}
+}
+
+func shardIndex(chatID int64, shards int) int {
+	if chatID < 0 {
+		chatID = -chatID
+	}
+	return int(chatID % int64(shards))
+}
+
+// updateChatID возвращает чат, к которому относится апдейт. Для инлайн-режима,
+// где чата нет, используется ID пользователя
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil && update.Message.Chat != nil:
+		return update.Message.Chat.ID
+	case update.EditedMessage != nil && update.EditedMessage.Chat != nil:
+		return update.EditedMessage.Chat.ID
+	case update.ChannelPost != nil && update.ChannelPost.Chat != nil:
+		return update.ChannelPost.Chat.ID
+	case update.EditedChannelPost != nil && update.EditedChannelPost.Chat != nil:
+		return update.EditedChannelPost.Chat.ID
+	case update.CallbackQuery != nil:
+		if update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil {
+			return update.CallbackQuery.Message.Chat.ID
+		}
+		if update.CallbackQuery.From != nil {
+			return int64(update.CallbackQuery.From.ID)
+		}
+	case update.InlineQuery != nil && update.InlineQuery.From != nil:
+		return int64(update.InlineQuery.From.ID)
+	case update.ChosenInlineResult != nil && update.ChosenInlineResult.From != nil:
+		return int64(update.ChosenInlineResult.From.ID)
+	}
+	return 0
+}
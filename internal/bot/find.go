@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	findPageSize = 5
+	// searchTTL — сколько работает кнопка следующей страницы поиска
+	searchTTL = time.Hour
+	// findNewFlag перед запросом исключает уже отправленные в чат видео
+	findNewFlag = "--new"
+
+	callbackFindPrefix = "find:"
+)
+
+const findUsage = "Используйте: /find [--new] запрос\n" +
+	"Пример: /find котики -собаки (смешные | мемы) муз*\n\n" +
+	"Слова через пробел — все теги сразу, | — любой из них, " +
+	"-тег — без этого тега, * — любое продолжение. " +
+	"--new показывает только видео, которых вы еще не видели"
+
+// savedSearch — запрос, для которого показаны кнопки листания
+type savedSearch struct {
+	query       tagquery.Expr
+	excludeSent bool
+	expires     time.Time
+}
+
+// searchRegistry хранит недавние запросы /find и карусели, чтобы в данных
+// кнопки хватало короткого ID: callback_data ограничена 64 байтами. После
+// перезапуска бота старые кнопки перестают работать
+type searchRegistry struct {
+	mu       sync.Mutex
+	next     int64
+	searches map[string]savedSearch
+}
+
+func newSearchRegistry() *searchRegistry {
+	return &searchRegistry{searches: make(map[string]savedSearch)}
+}
+
+// save запоминает запрос и возвращает его ID
+func (r *searchRegistry) save(query tagquery.Expr, excludeSent bool) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range r.searches {
+		if now.After(s.expires) {
+			delete(r.searches, id)
+		}
+	}
+
+	r.next++
+	id := strconv.FormatInt(r.next, 36)
+	r.searches[id] = savedSearch{query: query, excludeSent: excludeSent, expires: now.Add(searchTTL)}
+	return id
+}
+
+// get возвращает запрос, если он еще не устарел
+func (r *searchRegistry) get(id string) (savedSearch, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.searches[id]
+	if !ok || time.Now().After(s.expires) {
+		return savedSearch{}, false
+	}
+	return s, true
+}
+
+// HandleFindCommand обрабатывает /find [--new] <запрос>
+func (b *Bot) HandleFindCommand(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	excludeSent := len(args) > 0 && args[0] == findNewFlag
+	if excludeSent {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		b.SendMessage(msg.Chat.ID, findUsage)
+		return
+	}
+
+	query, err := tagquery.Parse(strings.Join(args, " "))
+	if err != nil {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Ошибка в запросе: %v\n\n%s", err, findUsage))
+		return
+	}
+
+	for _, tag := range tagquery.Tags(query) {
+		if err := b.Store.RecordTagRequest(msg.Chat.ID, tag); err != nil {
+			log.Printf("Failed to record request for tag %q: %v", tag, err)
+		}
+	}
+
+	id := b.searches.save(query, excludeSent)
+	b.sendFindPage(msg.Chat.ID, id, 0)
+}
+
+// HandleFindCallback показывает следующую страницу поиска
+func (b *Bot) HandleFindCallback(query *tgbotapi.CallbackQuery) {
+	id, offset, ok := parseFindCallback(query.Data)
+	if !ok {
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	if _, ok := b.searches.get(id); !ok {
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "Поиск устарел, повторите /find"))
+		return
+	}
+
+	b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+	b.sendFindPage(query.Message.Chat.ID, id, offset)
+}
+
+// sendFindPage отправляет страницу результатов и кнопку следующей.
+// При --new отправленные видео выпадают из выборки, поэтому следующая
+// страница всегда начинается с начала
+func (b *Bot) sendFindPage(chatID int64, id string, offset int) {
+	search, ok := b.searches.get(id)
+	if !ok {
+		b.SendMessage(chatID, "⌛ Поиск устарел, повторите /find")
+		return
+	}
+
+	videos, total, err := b.Store.FindVideos(search.query, database.FindOptions{
+		ChatID:      chatID,
+		ExcludeSent: search.excludeSent,
+		Limit:       findPageSize,
+		Offset:      offset,
+	})
+	if err != nil {
+		log.Printf("Failed to find videos by %q: %v", search.query, err)
+		b.SendMessage(chatID, "❌ Ошибка поиска")
+		return
+	}
+	if len(videos) == 0 {
+		if search.excludeSent && offset == 0 {
+			b.SendMessage(chatID, "🎉 Новых видео по этому запросу не осталось")
+		} else {
+			b.SendMessage(chatID, "❌ Ничего не найдено")
+		}
+		return
+	}
+
+	delivered := make(map[int64]bool)
+	for _, v := range videos {
+		if delivered[v.ID] {
+			continue
+		}
+		sent, err := b.deliver(chatID, v, nil)
+		if err != nil {
+			log.Printf("Failed to send video %d: %v", v.ID, err)
+			b.SendMessage(chatID, "❌ Не удалось отправить видео")
+			return
+		}
+		b.markDelivered(chatID, sent, models.SourceSearch)
+		for _, videoID := range sent {
+			delivered[videoID] = true
+		}
+	}
+
+	next := offset + len(videos)
+	text := fmt.Sprintf("🔎 Найдено: %d, показаны %d–%d", total, offset+1, next)
+	if search.excludeSent {
+		next = 0
+		text = fmt.Sprintf("🔎 Новых по запросу: %d, показано %d", total, len(videos))
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	if offset+len(videos) < total {
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Еще", fmt.Sprintf("%s%s:%d", callbackFindPrefix, id, next)),
+		))
+	}
+	if _, err := b.API.Send(reply); err != nil {
+		log.Printf("Failed to send search summary to chat %d: %v", chatID, err)
+	}
+}
+
+// parseFindCallback разбирает данные кнопки вида find:<id>:<offset>
+func parseFindCallback(data string) (string, int, bool) {
+	parts := strings.Split(strings.TrimPrefix(data, callbackFindPrefix), ":")
+	if len(parts) != 2 {
+		return "", 0, false
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil || offset < 0 {
+		return "", 0, false
+	}
+	return parts[0], offset, true
+}
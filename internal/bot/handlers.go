@@ -1,103 +1,170 @@
 package bot
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"tg-video-bot/internal/database"
 	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"tg-video-bot/pkg/utilities"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 // HandleUpdate обрабатывает все входящие апдейты
-func (b *Bot) HandleUpdate(update tgbotapi.Update) {
+func (b *Bot) HandleUpdate(update Update) {
+	if userID := updateSenderID(update); userID != 0 && b.roleOf(userID) == models.RoleBanned {
+		if update.CallbackQuery != nil {
+			b.API.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, "⛔ Доступ запрещен"))
+		}
+		return
+	}
+
 	switch {
 	case update.CallbackQuery != nil:
 		b.HandleCallbackQuery(update.CallbackQuery)
 
+	case update.InlineQuery != nil:
+		b.HandleInlineQuery(update.InlineQuery)
+
+	case update.ChosenInlineResult != nil:
+		b.HandleChosenInlineResult(update.ChosenInlineResult)
+
 	case update.Message != nil:
 		if update.Message.IsCommand() {
 			b.HandleCommand(update.Message)
-		} else if update.Message.Video != nil {
-			b.HandleVideoMessage(update.Message)
+		} else if media, ok := incomingMedia(update.Message, update.Extra); ok {
+			b.HandleMediaMessage(update, media)
 		} else {
 			b.HandleTextMessage(update.Message)
 		}
 	}
 }
 
+// updateSenderID возвращает автора апдейта или 0, если его нет
+func updateSenderID(update Update) int64 {
+	switch {
+	case update.Message != nil:
+		return senderID(update.Message)
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return int64(update.CallbackQuery.From.ID)
+	case update.InlineQuery != nil && update.InlineQuery.From != nil:
+		return int64(update.InlineQuery.From.ID)
+	case update.ChosenInlineResult != nil && update.ChosenInlineResult.From != nil:
+		return int64(update.ChosenInlineResult.From.ID)
+	}
+	return 0
+}
+
 // HandleCommand обрабатывает текстовые команды
 func (b *Bot) HandleCommand(msg *tgbotapi.Message) {
-	s, _ := json.Marshal(msg)
-	fmt.Println(string(s))
-	switch msg.Command() {
-	case "start":
-		b.ShowMainMenu(msg.Chat.ID)
-		if b.IsAdmin(int64(msg.From.ID)) && msg.Text == "⚙️ Админ-панель" {
-			b.ShowAdminMenu(msg.Chat.ID)
-		}
-	case "help":
-		b.SendHelpMessage(msg.Chat.ID)
-	case "add_tags":
-		b.HandleAddTagsCommand(msg)
-	case "get_by_tag":
-		b.HandleGetByTagCommand(msg)
-	case "get_video":
-		b.HandleGetVideoCommand(msg)
-	case "get_videos":
-		b.HandleGetVideosCommand(msg)
-	case "add_video":
-		b.HandleAddVideoCommand(msg)
-	case "list_videos":
-		b.HandleListVideosCommand(msg)
-	case "delete_video":
-		b.HandleDeleteVideoCommand(msg)
-	default:
-		b.SendUnknownCommand(msg.Chat.ID)
-	}
+	b.router.Dispatch(msg)
 }
 
-// HandleVideoMessage обрабатывает получение видео
-func (b *Bot) HandleVideoMessage(msg *tgbotapi.Message) {
-	// Проверяем права администратора
-	if !b.IsAdmin(int64(msg.From.ID)) {
-		//b.SendMessage(msg.Chat.ID, "❌ Только администраторы могут добавлять видео")
+// HandleMediaMessage сохраняет присланный файл (видео, GIF, фото, кружок
+// или документ) из update.Message. Хештеги из подписи сразу становятся тегами
+func (b *Bot) HandleMediaMessage(update Update, video models.Video) {
+	msg, extra := update.Message, update.Extra
+
+	// Загружать файлы можно в личном чате или в группе из ADMIN_GROUP_IDS
+	if !b.can(senderID(msg), models.RoleUploader) {
 		return
 	}
-	if !b.IsAdminGroup(msg.Chat.ID) {
-		//b.SendMessage(msg.Chat.ID, "❌ Только администраторы могут добавлять видео")
+	if !msg.Chat.IsPrivate() && !b.IsAdminGroup(msg.Chat.ID) {
 		return
 	}
 
-	video := models.Video{
-		FileID:  msg.Video.FileID,
-		Caption: msg.Caption,
+	// Файлы альбома сохраняются вместе, когда придут все части
+	if extra.MediaGroupID != "" {
+		b.bufferAlbumItem(update, video)
+		return
+	}
+
+	tags, caption := captionHashtags(msg.Caption, extra.CaptionEntities)
+	if !b.StripCaptionHashtags {
+		caption = msg.Caption
 	}
 
-	videoID, err := b.VideoRepository.SaveVideo(video)
+	video.Caption = caption
+	video.Tags = tags
+	video.UploaderID = senderID(msg)
+
+	videoID, err := b.Store.SaveVideo(video)
 	if err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			b.SendMessage(msg.Chat.ID, "⚠️ Это видео уже есть в базе")
+		var dup *database.DuplicateVideoError
+		if errors.As(err, &dup) {
+			b.handleDuplicateVideo(msg, dup.VideoID, tags)
 			return
 		}
-		log.Printf("Ошибка сохранения видео: %v", err)
-		b.SendMessage(msg.Chat.ID, "❌ Ошибка сохранения видео")
+		log.Printf("Ошибка сохранения файла: %v", err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка сохранения файла")
+		return
+	}
+
+	saved := fmt.Sprintf("✅ Сохранено: %s, ID %d", video.Type.Label(), videoID)
+	if len(tags) > 0 {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("%s\nТеги: %s", saved, strings.Join(tags, ", ")))
+		return
+	}
+
+	prompt := saved + "\nОтправьте теги через пробел или /cancel"
+	b.startDialog(msg, stateUploadTags, map[string]string{"video_id": strconv.FormatInt(videoID, 10)}, prompt, nil)
+}
+
+// handleDuplicateVideo сообщает о повторной загрузке и предлагает добавить
+// к сохраненному видео новые теги: из хештегов подписи или введенные вручную
+func (b *Bot) handleDuplicateVideo(msg *tgbotapi.Message, videoID int64, tags []string) {
+	existing, err := b.Store.GetVideoByID(videoID)
+	if err != nil {
+		log.Printf("Failed to get duplicate video %d: %v", videoID, err)
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ Этот файл уже есть в базе (ID: %d)", videoID))
+		return
+	}
+
+	text := fmt.Sprintf("⚠️ Этот файл уже есть в базе (ID: %d)", videoID)
+	if len(existing.Tags) > 0 {
+		text += "\nТеги: " + strings.Join(existing.Tags, ", ")
+	}
+
+	known := make(map[string]bool, len(existing.Tags))
+	for _, tag := range existing.Tags {
+		known[tag] = true
+	}
+	var newTags []string
+	for _, tag := range tags {
+		if !known[tag] {
+			newTags = append(newTags, tag)
+		}
+	}
+
+	data := map[string]string{"video_id": strconv.FormatInt(videoID, 10)}
+	if len(newTags) > 0 {
+		data["tags"] = strings.Join(newTags, " ")
+		text += "\n\nДобавить к нему новые теги: " + strings.Join(newTags, ", ") + "?"
+		b.startDialog(msg, stateMergeConfirm, data, text, confirmKeyboard())
 		return
 	}
 
-	response := fmt.Sprintf("✅ Видео сохранено (ID: %d)\nДобавьте теги командой:\n/add_tags %d тег1 тег2", videoID, videoID)
-	b.SendMessage(msg.Chat.ID, response)
+	text += "\n\nОтправьте теги, чтобы добавить их к этому файлу, или /cancel"
+	b.startDialog(msg, stateUploadTags, data, text, nil)
 }
 
 // HandleTextMessage обрабатывает обычные текстовые сообщения
 func (b *Bot) HandleTextMessage(msg *tgbotapi.Message) {
+	if b.HandleDialogMessage(msg) {
+		return
+	}
+
 	switch msg.Text {
 	case "📥 Добавить видео":
-		b.SendMessage(msg.Chat.ID, "Отправьте мне видео для сохранения")
+		b.SendMessage(msg.Chat.ID, "Отправьте мне видео, GIF, фото или кружок для сохранения")
 	case "🏷 Добавить теги":
-		b.SendMessage(msg.Chat.ID, "Введите ID видео и теги через пробел:\nПример: 5 котики смешные")
+		if b.can(senderID(msg), models.RoleUploader) {
+			b.startDialog(msg, stateAddTagsVideo, nil, "Введите ID видео:", nil)
+		}
 		/*case "🔍 Найти по тегу":
 			b.ShowPopularTags(msg.Chat.ID)
 		default:
@@ -111,9 +178,28 @@ func (b *Bot) HandleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	data := query.Data
 
 	switch {
-	case strings.HasPrefix(data, "tag_"):
-		tag := strings.TrimPrefix(data, "tag_")
-		b.SendVideosByTag(chatID, tag)
+	case data == callbackDialogYes || data == callbackDialogNo:
+		// Диалог сам отвечает на нажатие
+		b.HandleDialogCallback(query)
+		return
+
+	case strings.HasPrefix(data, callbackFindPrefix):
+		// Поиск сам отвечает на нажатие
+		b.HandleFindCallback(query)
+		return
+
+	case strings.HasPrefix(data, callbackTagsPrefix):
+		// Список тегов сам отвечает на нажатие
+		b.HandleTagsCallback(query)
+		return
+
+	case strings.HasPrefix(data, callbackCarouselPrefix):
+		// Карусель сама отвечает на нажатие
+		b.HandleCarouselCallback(query)
+		return
+
+	case strings.HasPrefix(data, callbackTagPrefix):
+		b.HandleTagButton(chatID, strings.TrimPrefix(data, callbackTagPrefix))
 
 	/*case data == "more_tags":
 	b.ShowMoreTags(chatID)*/
@@ -129,26 +215,15 @@ func (b *Bot) HandleCallbackQuery(query *tgbotapi.CallbackQuery) {
 // HandleAddTagsCommand обрабатывает команду добавления тегов
 func (b *Bot) HandleAddTagsCommand(msg *tgbotapi.Message) {
 	args := strings.Fields(msg.CommandArguments())
-	if len(args) < 2 {
-		b.SendMessage(msg.Chat.ID, "Используйте: /add_tags [ID видео] [теги через пробел]")
-		return
-	}
-
-	videoID, err := strconv.Atoi(args[0])
-	if err != nil {
-		b.SendMessage(msg.Chat.ID, "❌ Неверный ID видео")
-		return
-	}
-
-	tags := args[1:]
-	err = b.VideoRepository.AddTagsToVideo(int64(videoID), tags)
-	if err != nil {
-		log.Printf("Ошибка добавления тегов: %v", err)
-		b.SendMessage(msg.Chat.ID, "❌ Ошибка добавления тегов")
-		return
+	switch len(args) {
+	case 0:
+		// Недостающие аргументы спрашиваем по шагам
+		b.startDialog(msg, stateAddTagsVideo, nil, "Введите ID видео:", nil)
+	case 1:
+		b.dialogPickVideo(msg, args[0], stateAddTagsTags, "Введите теги через пробел:")
+	default:
+		b.addTags(msg.Chat.ID, args[0], args[1:])
 	}
-
-	b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ Добавлены теги: %s", strings.Join(tags, ", ")))
 }
 
 // HandleGetByTagCommand обрабатывает поиск по тегу
@@ -166,91 +241,96 @@ func (b *Bot) HandleGetByTagCommand(msg *tgbotapi.Message) {
 func (b *Bot) HandleGetVideoCommand(msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
 
-	// Получаем случайное непросмотренное видео
-	video, err := b.VideoRepository.GetRandomUnsentVideo(chatID, 1)
+	// Получаем случайное непросмотренное видео или повтор по политике
+	videos, notice, err := b.randomVideos(chatID, 1)
 	if err != nil {
-		if err.Error() == "no unsent videos available" {
-			b.SendMessage(chatID, "🎉 Вы уже просмотрели все доступные видео!")
-			return
-		}
 		log.Printf("Failed to get random video: %v", err)
 		b.SendMessage(chatID, "❌ Произошла ошибка при получении видео")
 		return
 	}
-
-	// Отправляем видео
-	videoMsg := tgbotapi.NewVideoShare(chatID, video[0].FileID)
-	if video[0].Caption != "" {
-		videoMsg.Caption = video[0].Caption
+	if len(videos) == 0 {
+		b.SendMessage(chatID, b.noVideosMessage())
+		return
 	}
+	if notice != "" {
+		b.SendMessage(chatID, notice)
+	}
+	video := videos[0]
 
 	// Добавляем кнопки с тегами
-	if len(video[0].Tags) > 0 {
-		videoMsg.ReplyMarkup = createVideoTagsKeyboard(video[0].Tags)
+	var markup interface{}
+	if len(video.Tags) > 0 {
+		markup = createVideoTagsKeyboard(video.Tags)
 	}
 
-	if _, err := b.API.Send(videoMsg); err != nil {
+	// Отправляем видео
+	sent, err := b.deliver(chatID, video, markup)
+	if err != nil {
 		log.Printf("Failed to send video: %v", err)
 		b.SendMessage(chatID, "❌ Не удалось отправить видео")
 		return
 	}
 
 	// Помечаем видео как отправленное
-	if err := b.VideoRepository.MarkVideoSent(chatID, video[0].ID); err != nil {
-		log.Printf("Failed to mark video as sent: %v", err)
-	}
+	b.markDelivered(chatID, sent, models.SourceRandom)
 }
 
-// HandleGetVideosCommand обрабатывает команду /get_videos
+// HandleGetVideosCommand обрабатывает команду /get_videos [количество]
 func (b *Bot) HandleGetVideosCommand(msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
 
-	// Получаем случайное непросмотренное видео
-	nums, err := strconv.Atoi(msg.CommandArguments())
-	videos, err := b.VideoRepository.GetRandomUnsentVideo(chatID, nums)
-	if err != nil {
-		if err.Error() == "no unsent videos available" {
-			b.SendMessage(chatID, "🎉 Вы уже просмотрели все доступные видео!")
+	nums := defaultGetVideosCount
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxGetVideosCount {
+			b.SendMessage(chatID, fmt.Sprintf("Используйте: /get_videos [количество от 1 до %d]", maxGetVideosCount))
 			return
 		}
+		nums = n
+	}
+
+	// Получаем случайные непросмотренные видео или повторы по политике
+	videos, notice, err := b.randomVideos(chatID, nums)
+	if err != nil {
 		log.Printf("Failed to get random video: %v", err)
 		b.SendMessage(chatID, "❌ Произошла ошибка при получении видео")
 		return
 	}
+	if len(videos) == 0 {
+		b.SendMessage(chatID, b.noVideosMessage())
+		return
+	}
+	if notice != "" {
+		b.SendMessage(chatID, notice)
+	}
 
+	// Файлы одного альбома уходят вместе, поэтому альбом не повторяем
+	delivered := make(map[int64]bool)
 	for _, v := range videos {
-		// Отправляем видео
-		videoMsg := tgbotapi.NewVideoShare(chatID, v.FileID)
-		if v.Caption != "" {
-			videoMsg.Caption = v.Caption
+		if delivered[v.ID] {
+			continue
 		}
-		if _, err := b.API.Send(videoMsg); err != nil {
+		// Отправляем видео
+		sent, err := b.deliver(chatID, v, nil)
+		if err != nil {
 			log.Printf("Failed to send video: %v", err)
 			b.SendMessage(chatID, "❌ Не удалось отправить видео")
 			return
 		}
 		// Помечаем видео как отправленное
-		if err := b.VideoRepository.MarkVideoSent(chatID, v.ID); err != nil {
-			log.Printf("Failed to mark video as sent: %v", err)
+		b.markDelivered(chatID, sent, models.SourceRandom)
+		for _, id := range sent {
+			delivered[id] = true
 		}
 	}
 }
 
 func (b *Bot) HandleAddVideoCommand(msg *tgbotapi.Message) {
-	if !b.IsAdmin(int64(msg.From.ID)) {
-		//b.SendMessage(msg.Chat.ID, "❌ Недостаточно прав")
-		return
-	}
-	b.SendMessage(msg.Chat.ID, "Отправьте видео для добавления в базу")
+	b.SendMessage(msg.Chat.ID, "Отправьте видео, GIF, фото, кружок или видеофайл документом для добавления в базу")
 }
 
 func (b *Bot) HandleListVideosCommand(msg *tgbotapi.Message) {
-	if !b.IsAdmin(int64(msg.From.ID)) {
-		b.SendMessage(msg.Chat.ID, "❌ Недостаточно прав")
-		return
-	}
-
-	videos, err := b.VideoRepository.GetAllVideos()
+	videos, err := b.Store.GetAllVideos()
 	if err != nil {
 		b.SendMessage(msg.Chat.ID, "❌ Ошибка получения списка видео")
 		return
@@ -259,7 +339,7 @@ func (b *Bot) HandleListVideosCommand(msg *tgbotapi.Message) {
 	var response strings.Builder
 	response.WriteString("📋 Список видео:\n\n")
 	for _, v := range videos {
-		response.WriteString(fmt.Sprintf("ID: %d\n", v.ID))
+		response.WriteString(fmt.Sprintf("ID: %d · %s\n", v.ID, videoSummary(v)))
 		if v.Caption != "" {
 			response.WriteString(fmt.Sprintf("Описание: %s\n", v.Caption))
 		}
@@ -269,91 +349,93 @@ func (b *Bot) HandleListVideosCommand(msg *tgbotapi.Message) {
 	b.SendMessage(msg.Chat.ID, response.String())
 }
 
+// HandleDeleteVideoCommand спрашивает подтверждение перед удалением видео
 func (b *Bot) HandleDeleteVideoCommand(msg *tgbotapi.Message) {
-	if !b.IsAdmin(int64(msg.From.ID)) {
-		b.SendMessage(msg.Chat.ID, "❌ Недостаточно прав")
+	arg := strings.TrimSpace(msg.CommandArguments())
+	if arg == "" {
+		b.startDialog(msg, stateDeleteVideo, nil, "Введите ID видео для удаления:", nil)
 		return
 	}
 
-	videoID, err := strconv.Atoi(msg.CommandArguments())
-	if err != nil {
-		b.SendMessage(msg.Chat.ID, "Используйте: /delete_video [ID видео]")
-		return
-	}
-
-	if err := b.VideoRepository.DeleteVideo(int64(videoID)); err != nil {
-		b.SendMessage(msg.Chat.ID, "❌ Ошибка удаления видео")
-		return
-	}
-
-	b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ Видео ID %d удалено", videoID))
+	b.dialogPickVideo(msg, arg, stateDeleteConfirm, "")
 }
 
-// SendVideosByTag отправляет видео по указанному тегу
+// SendVideosByTag отправляет первое видео по тегу с кнопками листания
 func (b *Bot) SendVideosByTag(chatID int64, tag string) {
-	videos, err := b.VideoRepository.GetVideosByTag(tag)
-	if err != nil || len(videos) == 0 {
-		b.SendMessage(chatID, "❌ Видео с тегом '"+tag+"' не найдено")
+	tag = utilities.NormalizeTag(tag)
+	if tag == "" {
+		b.SendMessage(chatID, "Укажите тег")
 		return
 	}
-
-	// Отправляем первое видео
-	video := videos[0]
-	msg := tgbotapi.NewVideoShare(chatID, video.FileID)
-	if video.Caption != "" {
-		msg.Caption = video.Caption
+	if err := b.Store.RecordTagRequest(chatID, tag); err != nil {
+		log.Printf("Failed to record request for tag %q: %v", tag, err)
 	}
-	b.API.Send(msg)
 
-	// Если есть еще видео - предлагаем кнопку "Показать еще"
-	/*if len(videos) > 1 {
-		b.ShowMoreVideosButton(chatID, tag, 1)
-	}*/
+	found, err := b.startCarousel(chatID, tagquery.Term{Tag: tag})
+	if err != nil {
+		log.Printf("Failed to send videos by tag %q: %v", tag, err)
+		b.SendMessage(chatID, "❌ Не удалось отправить видео")
+		return
+	}
+	if !found {
+		b.SendMessage(chatID, "❌ Видео с тегом '"+tag+"' не найдено")
+	}
 }
 
 // SendVideoByID отправляет конкретное видео по ID
 func (b *Bot) SendVideoByID(chatID, videoID int64) {
-	video, err := b.VideoRepository.GetVideoByID(videoID)
+	video, err := b.Store.GetVideoByID(videoID)
 	if err != nil {
 		b.SendMessage(chatID, "❌ Видео не найдено"+err.Error())
 		return
 	}
 
 	// Проверяем, не отправлялось ли уже это видео
-	if b.VideoRepository.IsVideoSent(chatID, videoID) {
+	if b.Store.IsVideoSent(chatID, videoID) {
 		b.SendMessage(chatID, "⚠️ Вы уже получали это видео ранее")
 		return
 	}
 
-	msg := tgbotapi.NewVideoShare(chatID, video.FileID)
-	if video.Caption != "" {
-		msg.Caption = video.Caption
+	sent, err := b.deliver(chatID, video, nil)
+	if err != nil {
+		log.Printf("Failed to send video %d: %v", videoID, err)
+		b.SendMessage(chatID, "❌ Не удалось отправить видео")
+		return
 	}
-	b.API.Send(msg)
 
 	// Запоминаем факт отправки
-	b.VideoRepository.MarkVideoSent(chatID, videoID)
+	b.markDelivered(chatID, sent, models.SourceButton)
 }
 
-// Вспомогательные методы для отправки сообщений
-func (b *Bot) SendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	b.API.Send(msg)
+// markDelivered запоминает отправку видео в чат: в истории, чтобы не
+// повторяться, и в событиях для статистики
+func (b *Bot) markDelivered(chatID int64, videoIDs []int64, source string) {
+	for _, videoID := range videoIDs {
+		// Для уже отправленного видео обновляется время просмотра
+		if err := b.Store.MarkVideoSent(chatID, videoID); err != nil {
+			log.Printf("Failed to mark video as sent: %v", err)
+		}
+		if err := b.Store.RecordDelivery(chatID, videoID, source); err != nil {
+			log.Printf("Failed to record delivery of video %d: %v", videoID, err)
+		}
+	}
 }
 
-func (b *Bot) SendHelpMessage(chatID int64) {
-	helpText := `📚 Доступные команды:
-/add_tags [ID] [теги] - Добавить теги к видео
-/get_by_tag [тег] - Найти видео по тегу
-/get_video [ID] - Получить видео по ID`
-	b.SendMessage(chatID, helpText)
+// Вспомогательные методы для отправки сообщений
+func (b *Bot) SendMessage(chatID int64, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	if _, err := b.API.Send(msg); err != nil {
+		log.Printf("Failed to send message to chat %d: %v", chatID, err)
+		return err
+	}
+	return nil
 }
 
 func (b *Bot) SendUnknownCommand(chatID int64) {
 	b.SendMessage(chatID, "❌ Неизвестная команда. Введите /help для списка команд")
 }
 
-// Создает клавиатуру с тегами видео
+// Создает клавиатуру с тегами видео: нажатие присылает новое видео с тегом
 func createVideoTagsKeyboard(tags []string) tgbotapi.InlineKeyboardMarkup {
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
@@ -361,7 +443,7 @@ func createVideoTagsKeyboard(tags []string) tgbotapi.InlineKeyboardMarkup {
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(
 				"#"+tag,
-				"tag_"+tag,
+				callbackTagPrefix+tag,
 			),
 		))
 	}
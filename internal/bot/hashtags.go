@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+	"tg-video-bot/pkg/utilities"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// captionHashtags возвращает теги из хештегов подписи и подпись без них.
+// Хештеги берутся из entities, которые размечает сам Telegram, поэтому
+// "#1" или "#" внутри ссылки тегами не считаются. Смещения entities
+// заданы в единицах UTF-16
+func captionHashtags(caption string, entities []tgbotapi.MessageEntity) ([]string, string) {
+	var hashtags []tgbotapi.MessageEntity
+	for _, e := range entities {
+		if e.Type == "hashtag" {
+			hashtags = append(hashtags, e)
+		}
+	}
+	if len(hashtags) == 0 {
+		return nil, caption
+	}
+	sort.Slice(hashtags, func(i, j int) bool { return hashtags[i].Offset < hashtags[j].Offset })
+
+	text := utf16.Encode([]rune(caption))
+	var tags []string
+	seen := make(map[string]bool)
+	var rest []uint16
+	pos := 0
+	for _, e := range hashtags {
+		start, end := e.Offset, e.Offset+e.Length
+		if start < pos || end > len(text) {
+			continue
+		}
+
+		tag := utilities.NormalizeTag(string(utf16.Decode(text[start:end])))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+
+		rest = append(rest, text[pos:start]...)
+		pos = end
+	}
+	rest = append(rest, text[pos:]...)
+
+	return tags, tidyCaption(string(utf16.Decode(rest)))
+}
+
+// tidyCaption схлопывает пробелы, оставшиеся после удаления хештегов,
+// и убирает строки, в которых были только хештеги
+func tidyCaption(caption string) string {
+	var lines []string
+	for _, line := range strings.Split(caption, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
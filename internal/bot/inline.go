@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// inlinePageSize — результатов в одном ответе, Telegram разрешает до 50
+	inlinePageSize = 20
+	// defaultInlineCacheTime — сколько Telegram хранит ответ для пользователя
+	defaultInlineCacheTime = 30 * time.Second
+	inlineTitleLen         = 64
+)
+
+// inlineCachedResult — InlineQueryResultCached* с file_id из базы. В
+// tgbotapi v4 нет кэшированных видео и документов, поэтому структура
+// общая для всех типов
+type inlineCachedResult struct {
+	Type           string `json:"type"`
+	ID             string `json:"id"`
+	VideoFileID    string `json:"video_file_id,omitempty"`
+	GifFileID      string `json:"gif_file_id,omitempty"`
+	PhotoFileID    string `json:"photo_file_id,omitempty"`
+	DocumentFileID string `json:"document_file_id,omitempty"`
+	Title          string `json:"title,omitempty"`
+	Description    string `json:"description,omitempty"`
+	Caption        string `json:"caption,omitempty"`
+}
+
+// HandleInlineQuery ищет видео для @bot <запрос> по тегам и подписям.
+// Запрос разбирается как в /find; если он не разбирается, ищется только
+// подпись. Первыми идут видео, которые пользователь еще не получал, поэтому
+// ответ помечается персональным
+func (b *Bot) HandleInlineQuery(query *tgbotapi.InlineQuery) {
+	userID := int64(query.From.ID)
+	offset, err := strconv.Atoi(query.Offset)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	text := strings.TrimSpace(query.Query)
+	var expr tagquery.Expr
+	if text != "" {
+		if e, err := tagquery.Parse(text); err == nil {
+			expr = e
+		}
+	}
+
+	videos, total, err := b.Store.FindVideos(expr, database.FindOptions{
+		ChatID:      userID,
+		UnseenFirst: true,
+		Caption:     text,
+		Limit:       inlinePageSize,
+		Offset:      offset,
+	})
+	if err != nil {
+		log.Printf("Failed to find videos for inline query %q: %v", text, err)
+	}
+
+	results := make([]interface{}, 0, len(videos))
+	for _, v := range videos {
+		if result, ok := inlineResult(v); ok {
+			results = append(results, result)
+		}
+	}
+
+	var nextOffset string
+	if next := offset + len(videos); next < total {
+		nextOffset = strconv.Itoa(next)
+	}
+	if err := b.answerInlineQuery(query.ID, results, nextOffset); err != nil {
+		log.Printf("Failed to answer inline query from user %d: %v", userID, err)
+	}
+}
+
+// HandleChosenInlineResult учитывает видео, отправленное через инлайн-режим.
+// Чат получателя боту неизвестен, поэтому отправка записывается в историю
+// личного чата пользователя. Telegram присылает такие апдейты, только если
+// в @BotFather включен inline feedback
+func (b *Bot) HandleChosenInlineResult(result *tgbotapi.ChosenInlineResult) {
+	userID := int64(result.From.ID)
+	videoID, err := strconv.ParseInt(result.ResultID, 10, 64)
+	if err != nil {
+		log.Printf("Unexpected inline result ID %q", result.ResultID)
+		return
+	}
+
+	// Запрос пишем здесь, а не в HandleInlineQuery: тот вызывается на
+	// каждое нажатие клавиши
+	if query, err := tagquery.Parse(result.Query); err == nil {
+		for _, tag := range tagquery.Tags(query) {
+			if err := b.Store.RecordTagRequest(userID, tag); err != nil {
+				log.Printf("Failed to record request for tag %q: %v", tag, err)
+			}
+		}
+	}
+
+	b.markDelivered(userID, []int64{videoID}, models.SourceInline)
+}
+
+// inlineResult описывает файл для ответа на инлайн-запрос. Кружки
+// в инлайн-режиме не отправляются
+func inlineResult(v models.Video) (inlineCachedResult, bool) {
+	result := inlineCachedResult{
+		ID:      strconv.FormatInt(v.ID, 10),
+		Title:   inlineTitle(v),
+		Caption: v.Caption,
+	}
+	if len(v.Tags) > 0 {
+		result.Description = "#" + strings.Join(v.Tags, " #")
+	}
+
+	switch v.Type {
+	case models.MediaVideo, "":
+		result.Type = "video"
+		result.VideoFileID = v.FileID
+	case models.MediaAnimation:
+		result.Type = "gif"
+		result.GifFileID = v.FileID
+	case models.MediaPhoto:
+		result.Type = "photo"
+		result.PhotoFileID = v.FileID
+	case models.MediaDocument:
+		result.Type = "document"
+		result.DocumentFileID = v.FileID
+	default:
+		return result, false
+	}
+	return result, true
+}
+
+// inlineTitle — заголовок результата: начало подписи или тип и ID
+func inlineTitle(v models.Video) string {
+	title := strings.Join(strings.Fields(v.Caption), " ")
+	if title == "" {
+		return fmt.Sprintf("%s ID %d", v.Type.Label(), v.ID)
+	}
+	if runes := []rune(title); len(runes) > inlineTitleLen {
+		title = string(runes[:inlineTitleLen]) + "…"
+	}
+	return title
+}
+
+// answerInlineQuery отвечает на инлайн-запрос через MakeRequest: в
+// Messenger нет AnswerInlineQuery, а результаты — собственные структуры
+func (b *Bot) answerInlineQuery(queryID string, results []interface{}, nextOffset string) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("inline_query_id", queryID)
+	params.Set("results", string(data))
+	params.Set("cache_time", strconv.Itoa(int(b.InlineCacheTime/time.Second)))
+	params.Set("is_personal", "true")
+	params.Set("next_offset", nextOffset)
+
+	_, err = b.API.MakeRequest("answerInlineQuery", params)
+	return err
+}
@@ -1,11 +1,18 @@
 package bot
 
-import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+import (
+	"log"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
 
 func (b *Bot) ShowMainMenu(chatID int64) {
 	msg := tgbotapi.NewMessage(chatID, "Выберите действие:")
 	msg.ReplyMarkup = mainMenuKeyboard()
-	b.API.Send(msg)
+	if _, err := b.API.Send(msg); err != nil {
+		log.Printf("Failed to send main menu: %v", err)
+	}
 }
 
 func mainMenuKeyboard() tgbotapi.ReplyKeyboardMarkup {
@@ -23,9 +30,13 @@ func mainMenuKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	)
 }
 
-func (b *Bot) ShowAdminMenu(chatID int64) {
-	if !b.IsAdmin(chatID) {
-		return
+func (b *Bot) ShowAdminMenu(chatID int64, role models.Role) {
+	// Кнопка /stats видна только тем, кому доступна команда
+	secondRow := tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("/delete_video"),
+	)
+	if role.AtLeast(models.RoleAdmin) {
+		secondRow = append(secondRow, tgbotapi.NewKeyboardButton("/stats"))
 	}
 
 	buttons := tgbotapi.NewReplyKeyboard(
@@ -33,13 +44,12 @@ func (b *Bot) ShowAdminMenu(chatID int64) {
 			tgbotapi.NewKeyboardButton("/list_videos"),
 			tgbotapi.NewKeyboardButton("/add_video"),
 		),
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("/delete_video"),
-			tgbotapi.NewKeyboardButton("/stats"),
-		),
+		secondRow,
 	)
 
 	msg := tgbotapi.NewMessage(chatID, "⚙️ Админ-панель")
 	msg.ReplyMarkup = buttons
-	b.API.Send(msg)
+	if _, err := b.API.Send(msg); err != nil {
+		log.Printf("Failed to send admin menu: %v", err)
+	}
 }
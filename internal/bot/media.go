@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"strings"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// incomingMedia извлекает из сообщения файл, который можно сохранить в
+// коллекцию. GIF приходит и как animation, и как document, поэтому
+// animation проверяется первой. Из документов принимаются только видео
+// и изображения
+func incomingMedia(msg *tgbotapi.Message, extra MessageExtra) (models.Video, bool) {
+	switch {
+	case msg.Video != nil:
+		v := msg.Video
+		return models.Video{
+			Type:         models.MediaVideo,
+			FileID:       v.FileID,
+			FileUniqueID: extra.Video.fileUniqueID(),
+			Duration:     v.Duration,
+			Width:        v.Width,
+			Height:       v.Height,
+			MimeType:     v.MimeType,
+			FileSize:     int64(v.FileSize),
+			ThumbFileID:  thumbFileID(v.Thumbnail),
+		}, true
+
+	case msg.Animation != nil:
+		a := msg.Animation
+		return models.Video{
+			Type:         models.MediaAnimation,
+			FileID:       a.FileID,
+			FileUniqueID: extra.Animation.fileUniqueID(),
+			Duration:     a.Duration,
+			Width:        a.Width,
+			Height:       a.Height,
+			MimeType:     a.MimeType,
+			FileSize:     int64(a.FileSize),
+			ThumbFileID:  thumbFileID(a.Thumbnail),
+		}, true
+
+	case msg.VideoNote != nil:
+		n := msg.VideoNote
+		return models.Video{
+			Type:         models.MediaVideoNote,
+			FileID:       n.FileID,
+			FileUniqueID: extra.VideoNote.fileUniqueID(),
+			Duration:     n.Duration,
+			Width:        n.Length,
+			Height:       n.Length,
+			FileSize:     int64(n.FileSize),
+			ThumbFileID:  thumbFileID(n.Thumbnail),
+		}, true
+
+	case msg.Photo != nil && len(*msg.Photo) > 0:
+		// Размеры идут по возрастанию, сохраняем самый большой
+		sizes := *msg.Photo
+		p := sizes[len(sizes)-1]
+		v := models.Video{
+			Type:     models.MediaPhoto,
+			FileID:   p.FileID,
+			Width:    p.Width,
+			Height:   p.Height,
+			MimeType: "image/jpeg",
+			FileSize: int64(p.FileSize),
+		}
+		if len(extra.Photo) == len(sizes) {
+			v.FileUniqueID = extra.Photo[len(sizes)-1].FileUniqueID
+		}
+		return v, true
+
+	case msg.Document != nil && isMediaMimeType(msg.Document.MimeType):
+		d := msg.Document
+		return models.Video{
+			Type:         models.MediaDocument,
+			FileID:       d.FileID,
+			FileUniqueID: extra.Document.fileUniqueID(),
+			MimeType:     d.MimeType,
+			FileSize:     int64(d.FileSize),
+			ThumbFileID:  thumbFileID(d.Thumbnail),
+		}, true
+	}
+
+	return models.Video{}, false
+}
+
+func isMediaMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "image/")
+}
+
+func thumbFileID(thumb *tgbotapi.PhotoSize) string {
+	if thumb == nil {
+		return ""
+	}
+	return thumb.FileID
+}
+
+// mediaConfig строит send*-запрос, подходящий типу файла. У кружков
+// нет подписи, поэтому для них Caption не передается
+func mediaConfig(chatID int64, v models.Video, markup interface{}) tgbotapi.Chattable {
+	switch v.Type {
+	case models.MediaAnimation:
+		c := tgbotapi.NewAnimationShare(chatID, v.FileID)
+		c.Caption = v.Caption
+		c.ReplyMarkup = markup
+		return c
+	case models.MediaPhoto:
+		c := tgbotapi.NewPhotoShare(chatID, v.FileID)
+		c.Caption = v.Caption
+		c.ReplyMarkup = markup
+		return c
+	case models.MediaVideoNote:
+		c := tgbotapi.NewVideoNoteShare(chatID, v.Width, v.FileID)
+		c.ReplyMarkup = markup
+		return c
+	case models.MediaDocument:
+		c := tgbotapi.NewDocumentShare(chatID, v.FileID)
+		c.Caption = v.Caption
+		c.ReplyMarkup = markup
+		return c
+	}
+
+	c := tgbotapi.NewVideoShare(chatID, v.FileID)
+	c.Caption = v.Caption
+	c.ReplyMarkup = markup
+	return c
+}
+
+// sendMedia отправляет элемент коллекции в чат методом его типа
+func (b *Bot) sendMedia(chatID int64, v models.Video, markup interface{}) error {
+	_, err := b.API.Send(mediaConfig(chatID, v, markup))
+	return err
+}
@@ -0,0 +1,18 @@
+package bot
+
+import (
+	"net/url"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Messenger — часть Telegram Bot API, через которую обработчики отвечают
+// пользователям. *tgbotapi.BotAPI реализует его напрямую
+type Messenger interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error)
+	// MakeRequest вызывает метод Bot API, для которого в библиотеке нет конфигурации
+	MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error)
+}
+
+var _ Messenger = (*tgbotapi.BotAPI)(nil)
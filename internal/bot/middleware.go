@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// recoverPanic не дает панике в обработке уронить весь бот: пишет ее в лог
+// и, если chatID не 0, сообщает об ошибке в чат. Вызывается через defer
+func (b *Bot) recoverPanic(what string, chatID int64) {
+	if r := recover(); r != nil {
+		log.Printf("Panic in %s: %v\n%s", what, r, debug.Stack())
+		if chatID != 0 {
+			b.SendMessage(chatID, "❌ Произошла внутренняя ошибка")
+		}
+	}
+}
+
+// logMiddleware пишет в лог команду, автора и время обработки
+func (b *Bot) logMiddleware(cmd *Command, next HandlerFunc) HandlerFunc {
+	return func(msg *tgbotapi.Message) {
+		start := time.Now()
+		next(msg)
+		log.Printf("/%s from user %d in chat %d handled in %v", cmd.Name, senderID(msg), msg.Chat.ID, time.Since(start))
+	}
+}
+
+// authMiddleware пропускает только пользователей с ролью не ниже требуемой
+func (b *Bot) authMiddleware(cmd *Command, next HandlerFunc) HandlerFunc {
+	return func(msg *tgbotapi.Message) {
+		if !b.userRole(msg).AtLeast(cmd.Role) {
+			b.SendMessage(msg.Chat.ID, "❌ Недостаточно прав")
+			return
+		}
+		next(msg)
+	}
+}
+
+// rateLimitMiddleware ограничивает частоту команд от одного пользователя
+func (b *Bot) rateLimitMiddleware(cmd *Command, next HandlerFunc) HandlerFunc {
+	return func(msg *tgbotapi.Message) {
+		if !b.limiter.Allow(senderID(msg)) {
+			b.SendMessage(msg.Chat.ID, "⏳ Слишком много команд, подождите немного")
+			return
+		}
+		next(msg)
+	}
+}
+
+// commandLimiter — token bucket на каждого пользователя
+type commandLimiter struct {
+	burst    float64
+	interval time.Duration
+
+	mu      sync.Mutex
+	buckets map[int64]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newCommandLimiter разрешает burst команд подряд и затем одну за interval
+func newCommandLimiter(burst int, interval time.Duration) *commandLimiter {
+	return &commandLimiter{
+		burst:    float64(burst),
+		interval: interval,
+		buckets:  make(map[int64]*tokenBucket),
+	}
+}
+
+// Allow списывает токен пользователя, если он есть
+func (l *commandLimiter) Allow(userID int64) bool {
+	if l.burst <= 0 || l.interval <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[userID] = bucket
+	}
+
+	bucket.tokens += float64(now.Sub(bucket.last)) / float64(l.interval)
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// senderID возвращает автора сообщения или 0 для постов каналов
+func senderID(msg *tgbotapi.Message) int64 {
+	if msg.From == nil {
+		return 0
+	}
+	return int64(msg.From.ID)
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"sync"
+)
+
+// offsetTracker следит за тем, до какого update_id включительно все апдейты
+// обработаны. Воркеры завершают апдейты не по порядку, поэтому граница
+// сдвигается только через непрерывный префикс готовых апдейтов. Отложенный
+// апдейт (Hold) границу не сдвигает, но и не задерживает чтение новых
+type offsetTracker struct {
+	mu    sync.Mutex
+	queue []int
+	done  map[int]bool
+	held  map[int]bool
+	last  int
+	// started — наибольший принятый в работу update_id
+	started int
+	// changed закрывается и заменяется новым при каждом Done и Hold
+	changed chan struct{}
+}
+
+func newOffsetTracker(last int) *offsetTracker {
+	return &offsetTracker{
+		done:    make(map[int]bool),
+		held:    make(map[int]bool),
+		last:    last,
+		started: last,
+		changed: make(chan struct{}),
+	}
+}
+
+// Start отмечает апдейт как принятый в работу. ID должны возрастать
+func (t *offsetTracker) Start(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queue = append(t.queue, id)
+	if id > t.started {
+		t.started = id
+	}
+}
+
+// Hold отмечает апдейт, обработка которого продолжится позже, например
+// файл альбома, ждущий остальные части. Пока не вызван Done, offset не
+// сдвигается дальше него
+func (t *offsetTracker) Hold(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.held[id] = true
+	t.notify()
+}
+
+// Done отмечает апдейт как обработанный
+func (t *offsetTracker) Done(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done[id] = true
+	delete(t.held, id)
+	for len(t.queue) > 0 && t.done[t.queue[0]] {
+		// В режиме webhook апдейты могут прийти не по порядку
+		if t.queue[0] > t.last {
+			t.last = t.queue[0]
+		}
+		delete(t.done, t.queue[0])
+		t.queue = t.queue[1:]
+	}
+	t.notify()
+}
+
+func (t *offsetTracker) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// Wait ждет, пока все апдейты до id включительно будут обработаны или
+// отложены. Возвращает false, если ctx отменен раньше
+func (t *offsetTracker) Wait(ctx context.Context, id int) bool {
+	for {
+		t.mu.Lock()
+		settled, changed := t.settled(id), t.changed
+		t.mu.Unlock()
+		if settled {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (t *offsetTracker) settled(id int) bool {
+	if t.last >= id {
+		return true
+	}
+	if t.started < id {
+		return false
+	}
+	for _, queued := range t.queue {
+		if queued <= id && !t.done[queued] && !t.held[queued] {
+			return false
+		}
+	}
+	return true
+}
+
+// Last возвращает последний update_id, до которого включительно все обработано
+func (t *offsetTracker) Last() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
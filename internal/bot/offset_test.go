@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOffsetTrackerHold(t *testing.T) {
+	offsets := newOffsetTracker(0)
+	for id := 1; id <= 3; id++ {
+		offsets.Start(id)
+	}
+
+	offsets.Done(1)
+	offsets.Hold(2)
+	offsets.Done(3)
+	if last := offsets.Last(); last != 1 {
+		t.Fatalf("Last() = %d with update 2 held, want 1", last)
+	}
+
+	// Отложенный апдейт не задерживает чтение следующих
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !offsets.Wait(ctx, 3) {
+		t.Fatal("Wait(3) blocked on a held update")
+	}
+
+	offsets.Done(2)
+	if last := offsets.Last(); last != 3 {
+		t.Fatalf("Last() = %d after held update is done, want 3", last)
+	}
+}
+
+func TestOffsetTrackerWaitsForUnstarted(t *testing.T) {
+	offsets := newOffsetTracker(0)
+	offsets.Start(1)
+	offsets.Done(1)
+
+	// Апдейт 2 уже получен, но еще не принят в работу
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if offsets.Wait(ctx, 2) {
+		t.Fatal("Wait(2) returned before update 2 was started")
+	}
+}
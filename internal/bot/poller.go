@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const pollTimeout = 60
+
+// heldPollInterval — пауза между запросами, пока есть отложенные апдейты:
+// Telegram возвращает их сразу, не дожидаясь новых
+const heldPollInterval = 250 * time.Millisecond
+
+// poll получает апдейты через getUpdates после offsets.Last(), пока ctx не
+// отменен. Запрос со следующим offset подтверждает Telegram всю пачку, поэтому
+// он уходит только после того, как пачка обработана: иначе апдейты из очередей
+// пропали бы при падении. Отложенные апдейты (см. offsetTracker.Hold) не
+// подтверждаются, пока не будут обработаны: Telegram присылает их снова, и
+// poll их пропускает. Канал закрывается сразу после отмены: апдейты из
+// незавершенного long poll отбрасываются и придут снова при следующем
+// запуске, так как они не подтверждены
+func poll(ctx context.Context, api *tgbotapi.BotAPI, offsets *offsetTracker) UpdatesChannel {
+	ch := make(chan Update, api.Buffer)
+	fetched := make(chan []Update)
+
+	go func() {
+		defer close(fetched)
+
+		// next — первый update_id, который еще не передан боту
+		next := offsets.Last() + 1
+		u := tgbotapi.NewUpdate(next)
+		u.Timeout = pollTimeout
+		for ctx.Err() == nil {
+			u.Offset = offsets.Last() + 1
+			updates, err := getUpdates(api, u)
+			if err != nil {
+				log.Printf("Failed to get updates, retrying in 3 seconds: %v", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(3 * time.Second):
+				}
+				continue
+			}
+
+			var fresh []Update
+			for _, update := range updates {
+				if update.UpdateID >= next {
+					fresh = append(fresh, update)
+					next = update.UpdateID + 1
+				}
+			}
+
+			if len(fresh) > 0 {
+				select {
+				case fetched <- fresh:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if !offsets.Wait(ctx, next-1) {
+				return
+			}
+			if offsets.Last() < next-1 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(heldPollInterval):
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case updates, ok := <-fetched:
+				if !ok {
+					return
+				}
+				for _, update := range updates {
+					select {
+					case ch <- update:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// confirmOffset сообщает Telegram, что все апдейты до last включительно
+// обработаны, чтобы они не пришли повторно после перезапуска
+func confirmOffset(api *tgbotapi.BotAPI, last int) error {
+	if last <= 0 {
+		return nil
+	}
+
+	v := url.Values{}
+	v.Add("offset", strconv.Itoa(last+1))
+	v.Add("limit", "1")
+	v.Add("timeout", "0")
+	_, err := api.MakeRequest("getUpdates", v)
+	return err
+}
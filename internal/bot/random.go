@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"tg-video-bot/pkg/utilities"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// callbackTagPrefix — кнопка тега под видео: присылает случайное новое видео
+// с этим тегом
+const callbackTagPrefix = "tag_"
+
+const randomUsage = "Используйте: /random [запрос]\n" +
+	"Пример: /random котики -собаки\n\n" +
+	"Присылает случайное видео под запрос, которого вы еще не видели. " +
+	"Запрос — как в /find, без запроса — любое новое видео"
+
+// HandleRandomCommand обрабатывает /random [запрос]
+func (b *Bot) HandleRandomCommand(msg *tgbotapi.Message) {
+	text := strings.TrimSpace(msg.CommandArguments())
+	if text == "" {
+		b.HandleGetVideoCommand(msg)
+		return
+	}
+
+	query, err := tagquery.Parse(text)
+	if err != nil {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Ошибка в запросе: %v\n\n%s", err, randomUsage))
+		return
+	}
+	b.sendRandomByQuery(msg.Chat.ID, query)
+}
+
+// HandleTagButton присылает случайное новое видео по кнопке тега
+func (b *Bot) HandleTagButton(chatID int64, tag string) {
+	tag = utilities.NormalizeTag(tag)
+	if tag == "" {
+		return
+	}
+	b.sendRandomByQuery(chatID, tagquery.Term{Tag: tag})
+}
+
+// sendRandomByQuery отправляет случайное видео под запрос, которое еще не
+// отправлялось в чат. Под видео — кнопки его тегов, чтобы продолжить
+func (b *Bot) sendRandomByQuery(chatID int64, query tagquery.Expr) {
+	for _, tag := range tagquery.Tags(query) {
+		if err := b.Store.RecordTagRequest(chatID, tag); err != nil {
+			log.Printf("Failed to record request for tag %q: %v", tag, err)
+		}
+	}
+
+	videos, _, err := b.Store.SampleVideos(query, database.FindOptions{
+		ChatID:      chatID,
+		ExcludeSent: true,
+		Limit:       1,
+	})
+	if err != nil {
+		log.Printf("Failed to get random video by %q: %v", query, err)
+		b.SendMessage(chatID, "❌ Произошла ошибка при получении видео")
+		return
+	}
+	if len(videos) == 0 {
+		b.SendMessage(chatID, b.noRandomMessage(chatID, query))
+		return
+	}
+	video := videos[0]
+
+	var markup interface{}
+	if len(video.Tags) > 0 {
+		markup = createVideoTagsKeyboard(video.Tags)
+	}
+	sent, err := b.deliver(chatID, video, markup)
+	if err != nil {
+		log.Printf("Failed to send video: %v", err)
+		b.SendMessage(chatID, "❌ Не удалось отправить видео")
+		return
+	}
+
+	b.markDelivered(chatID, sent, models.SourceTag)
+}
+
+// noRandomMessage объясняет, почему по запросу нечего прислать: видео нет
+// совсем или чат их уже видел
+func (b *Bot) noRandomMessage(chatID int64, query tagquery.Expr) string {
+	_, total, err := b.Store.FindVideos(query, database.FindOptions{ChatID: chatID, Limit: 1})
+	if err != nil {
+		log.Printf("Failed to count videos by %q: %v", query, err)
+	}
+	if total == 0 {
+		return fmt.Sprintf("❌ По запросу «%s» видео не найдено", query)
+	}
+	return fmt.Sprintf("🎉 Вы уже видели все видео по запросу «%s» (%d).\n"+
+		"Посмотреть их снова: /find %s\nНачать заново: /reset_history", query, total, query)
+}
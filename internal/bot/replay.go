@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// ReplayMode — что делать, когда чат посмотрел все видео
+type ReplayMode string
+
+const (
+	// ReplayNone — сообщать, что новых видео нет, пока историю не сбросят
+	ReplayNone ReplayMode = "none"
+	// ReplayReset — очищать историю чата и начинать новый круг
+	ReplayReset ReplayMode = "reset"
+	// ReplayLeastRecent — повторять видео, которые чат видел давнее всего
+	ReplayLeastRecent ReplayMode = "least_recent"
+	// ReplayOlderThan — повторять только видео, просмотренные раньше
+	// чем ReplayPolicy.After назад
+	ReplayOlderThan ReplayMode = "older_than"
+)
+
+const (
+	defaultReplayAfter = 30 * 24 * time.Hour
+
+	// defaultGetVideosCount — сколько видео присылает /get_videos без аргумента
+	defaultGetVideosCount = 5
+	// maxGetVideosCount ограничивает /get_videos, чтобы не упираться в лимиты отправки
+	maxGetVideosCount = 10
+)
+
+// ReplayPolicy задает повторы для /get_video и /get_videos
+type ReplayPolicy struct {
+	Mode ReplayMode
+	// After — для ReplayOlderThan: сколько должно пройти после просмотра
+	After time.Duration
+}
+
+// ParseReplayMode разбирает имя режима повторов
+func ParseReplayMode(name string) (ReplayMode, bool) {
+	switch mode := ReplayMode(strings.ToLower(name)); mode {
+	case ReplayNone, ReplayReset, ReplayLeastRecent, ReplayOlderThan:
+		return mode, true
+	}
+	return "", false
+}
+
+// randomVideos выбирает до limit случайных новых видео для чата и, если
+// новых не хватило, добирает их по политике повторов. notice — пояснение
+// для пользователя, если в выборку попали повторы
+func (b *Bot) randomVideos(chatID int64, limit int) (videos []models.Video, notice string, err error) {
+	videos, err = b.Store.GetRandomUnsentVideo(chatID, limit)
+	if err != nil || len(videos) == limit {
+		return videos, "", err
+	}
+
+	switch b.Replay.Mode {
+	case ReplayReset:
+		// Новый круг начинается, только когда новых видео не осталось совсем
+		if len(videos) > 0 {
+			return videos, "", nil
+		}
+		if _, err := b.Store.ResetSentVideos(chatID); err != nil {
+			return nil, "", err
+		}
+		videos, err = b.Store.GetRandomUnsentVideo(chatID, limit)
+		if err != nil || len(videos) == 0 {
+			return videos, "", err
+		}
+		return videos, "🔄 Вы посмотрели все видео, начинаем новый круг", nil
+
+	case ReplayLeastRecent, ReplayOlderThan:
+		var seenBefore time.Time
+		if b.Replay.Mode == ReplayOlderThan {
+			seenBefore = time.Now().Add(-b.Replay.After)
+		}
+		replay, err := b.Store.GetReplayVideos(chatID, seenBefore, limit-len(videos))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(replay) > 0 {
+			notice = "🔁 Новые видео закончились, повторяем давно просмотренные"
+		}
+		return append(videos, replay...), notice, nil
+	}
+
+	return videos, "", nil
+}
+
+// noVideosMessage — ответ, когда randomVideos ничего не нашел
+func (b *Bot) noVideosMessage() string {
+	switch b.Replay.Mode {
+	case ReplayReset, ReplayLeastRecent:
+		// Повторы включены, значит база пуста
+		return "📭 В базе пока нет видео"
+	case ReplayOlderThan:
+		return fmt.Sprintf("🎉 Вы уже просмотрели все доступные видео!\n"+
+			"Повторы начнутся через %d дн. после просмотра. Начать заново сейчас: /reset_history",
+			int(b.Replay.After/(24*time.Hour)))
+	}
+	return "🎉 Вы уже просмотрели все доступные видео!\nНачать заново: /reset_history"
+}
+
+// HandleResetHistoryCommand спрашивает подтверждение и очищает историю
+// просмотров текущего чата. В группах это могут только модераторы
+func (b *Bot) HandleResetHistoryCommand(msg *tgbotapi.Message) {
+	if !msg.Chat.IsPrivate() && !b.can(senderID(msg), models.RoleModerator) {
+		b.SendMessage(msg.Chat.ID, "❌ В группе историю просмотров сбрасывают модераторы")
+		return
+	}
+
+	data := map[string]string{"chat_id": strconv.FormatInt(msg.Chat.ID, 10)}
+	b.startDialog(msg, stateResetConfirm, data,
+		"Сбросить историю просмотров? Все видео снова будут считаться новыми", confirmKeyboard())
+}
+
+// HandleResetChatCommand обрабатывает /reset_chat [ID чата]: сброс истории
+// просмотров любого чата. Без аргумента сбрасывает текущий
+func (b *Bot) HandleResetChatCommand(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			b.SendMessage(msg.Chat.ID, "Используйте: /reset_chat [ID чата]")
+			return
+		}
+		chatID = id
+	}
+
+	data := map[string]string{"chat_id": strconv.FormatInt(chatID, 10)}
+	b.startDialog(msg, stateResetChatConfirm, data,
+		fmt.Sprintf("Сбросить историю просмотров чата %d?", chatID), confirmKeyboard())
+}
+
+// resetHistory очищает историю чата target и сообщает результат в chatID
+func (b *Bot) resetHistory(chatID int64, target string) {
+	targetID, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		b.SendMessage(chatID, "❌ Неверный ID чата")
+		return
+	}
+
+	n, err := b.Store.ResetSentVideos(targetID)
+	if err != nil {
+		log.Printf("Failed to reset history of chat %d: %v", targetID, err)
+		b.SendMessage(chatID, "❌ Ошибка сброса истории")
+		return
+	}
+
+	if targetID == chatID {
+		b.SendMessage(chatID, fmt.Sprintf("🔄 История просмотров сброшена (видео: %d)", n))
+		return
+	}
+	b.SendMessage(chatID, fmt.Sprintf("🔄 История просмотров чата %d сброшена (видео: %d)", targetID, n))
+}
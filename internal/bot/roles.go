@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// HandleGrantCommand обрабатывает /grant <пользователь> <роль>.
+// Пользователя можно указать ID или ответом на его сообщение
+func (b *Bot) HandleGrantCommand(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	userID, args, ok := commandTarget(msg, args)
+	if !ok || len(args) != 1 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /grant [ID пользователя] [роль]\nРоли: "+roleNames())
+		return
+	}
+
+	role, ok := models.ParseRole(strings.ToLower(args[0]))
+	if !ok {
+		b.SendMessage(msg.Chat.ID, "❌ Неизвестная роль. Доступные роли: "+roleNames())
+		return
+	}
+	if reason, ok := b.canChangeRole(msg, userID, role); !ok {
+		b.SendMessage(msg.Chat.ID, reason)
+		return
+	}
+
+	if err := b.Store.SetUserRole(userID, role, senderID(msg)); err != nil {
+		log.Printf("Failed to grant %s to user %d: %v", role, userID, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка сохранения роли")
+		return
+	}
+	if err := b.syncUserCommands(userID, role); err != nil {
+		log.Printf("Failed to set commands for user %d: %v", userID, err)
+	}
+
+	b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ Пользователю %d выдана роль %s", userID, role))
+}
+
+// HandleRevokeCommand обрабатывает /revoke <пользователь> и возвращает
+// пользователю роль по умолчанию
+func (b *Bot) HandleRevokeCommand(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	userID, args, ok := commandTarget(msg, args)
+	if !ok || len(args) != 0 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /revoke [ID пользователя]")
+		return
+	}
+	if reason, ok := b.canChangeRole(msg, userID, models.RoleViewer); !ok {
+		b.SendMessage(msg.Chat.ID, reason)
+		return
+	}
+
+	if err := b.Store.DeleteUserRole(userID); err != nil {
+		log.Printf("Failed to revoke role of user %d: %v", userID, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка сброса роли")
+		return
+	}
+	if err := b.syncUserCommands(userID, models.RoleViewer); err != nil {
+		log.Printf("Failed to reset commands for user %d: %v", userID, err)
+	}
+
+	b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ Роль пользователя %d сброшена до %s", userID, models.RoleViewer))
+}
+
+// canChangeRole проверяет, может ли автор сообщения сменить роль userID на
+// role, и объясняет отказ. Роль владельцев из ADMIN_IDS задается
+// конфигурацией: BootstrapOwners вернул бы ее при перезапуске. Выдавать и
+// снимать роль owner могут только они, иначе новый владелец мог бы
+// отобрать права у тех, кто его назначил
+func (b *Bot) canChangeRole(msg *tgbotapi.Message, userID int64, role models.Role) (string, bool) {
+	if userID == senderID(msg) {
+		return "❌ Нельзя изменить собственную роль", false
+	}
+	if b.isBootstrapOwner(userID) {
+		return fmt.Sprintf("❌ Пользователь %d — владелец из ADMIN_IDS, его роль меняется только в конфигурации", userID), false
+	}
+	if b.isBootstrapOwner(senderID(msg)) {
+		return "", true
+	}
+	if role == models.RoleOwner || b.roleOf(userID) == models.RoleOwner {
+		return "❌ Выдавать и снимать роль owner могут только владельцы из ADMIN_IDS", false
+	}
+	return "", true
+}
+
+// commandTarget определяет пользователя, к которому относится команда:
+// автора сообщения, на которое ответили, или ID из первого аргумента.
+// Возвращает оставшиеся аргументы
+func commandTarget(msg *tgbotapi.Message, args []string) (int64, []string, bool) {
+	if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil {
+		return int64(msg.ReplyToMessage.From.ID), args, true
+	}
+	if len(args) == 0 {
+		return 0, nil, false
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || userID == 0 {
+		return 0, nil, false
+	}
+	return userID, args[1:], true
+}
+
+func roleNames() string {
+	names := make([]string, len(models.Roles))
+	for i, role := range models.Roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tgtest"
+)
+
+// TestBootstrapOwnersOverridesStoredRole проверяет, что пользователь из
+// ADMIN_IDS становится владельцем, даже если раньше получил другую роль
+// или бан, и сразу может пользоваться правами владельца
+func TestBootstrapOwnersOverridesStoredRole(t *testing.T) {
+	stores := map[string]func(t *testing.T) database.Store{
+		"memory": func(t *testing.T) database.Store { return database.NewMemoryStore() },
+		"sqlite": func(t *testing.T) database.Store {
+			db, err := database.InitSQLite(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := database.NewSQLiteRepository(db)
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			stored := map[int64]models.Role{
+				testOwner:     models.RoleBanned,
+				testOwner + 1: models.RoleViewer,
+				testOwner + 2: models.RoleAdmin,
+			}
+			for id, role := range stored {
+				if err := store.SetUserRole(id, role, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			srv := tgtest.NewServer()
+			defer srv.Close()
+			api, err := srv.NewBotAPI()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := New(api, store)
+			if err := b.BootstrapOwners([]int64{testOwner, testOwner + 1, testOwner + 2}); err != nil {
+				t.Fatal(err)
+			}
+
+			for id := range stored {
+				role, err := store.GetUserRole(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if role != models.RoleOwner {
+					t.Errorf("user %d has role %s after bootstrap, want %s", id, role, models.RoleOwner)
+				}
+			}
+
+			startPolling(t, srv, b)
+			srv.PushMessage(testOwner, testOwner, "/grant 30 admin")
+			reply := waitRequest(t, srv, "sendMessage", 1)
+			if text := reply.Params.Get("text"); !strings.HasPrefix(text, "✅") {
+				t.Fatalf("/grant by formerly banned owner: reply %q, want success", text)
+			}
+			if role, _ := store.GetUserRole(30); role != models.RoleAdmin {
+				t.Fatalf("user 30 has role %s, want %s", role, models.RoleAdmin)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// HandlerFunc обрабатывает сообщение с командой
+type HandlerFunc func(msg *tgbotapi.Message)
+
+// Middleware оборачивает обработчик команды cmd
+type Middleware func(cmd *Command, next HandlerFunc) HandlerFunc
+
+// Command описывает команду бота
+type Command struct {
+	// Name — имя без слэша, как в /help
+	Name string
+	// Args — синтаксис аргументов для /help, например "[ID] [теги]"
+	Args        string
+	Description string
+	// Role — минимальная роль, которой доступна команда
+	Role    models.Role
+	Handler HandlerFunc
+}
+
+// Usage возвращает строку вида "/name args"
+func (c *Command) Usage() string {
+	if c.Args == "" {
+		return "/" + c.Name
+	}
+	return "/" + c.Name + " " + c.Args
+}
+
+// Router выбирает обработчик по имени команды и пропускает вызов
+// через цепочку middleware
+type Router struct {
+	commands   map[string]*Command
+	middleware []Middleware
+	notFound   HandlerFunc
+}
+
+// NewRouter создает пустой роутер. notFound вызывается для неизвестных команд
+func NewRouter(notFound HandlerFunc) *Router {
+	return &Router{
+		commands: make(map[string]*Command),
+		notFound: notFound,
+	}
+}
+
+// Use добавляет middleware. Первая добавленная выполняется первой
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handle регистрирует команду
+func (r *Router) Handle(cmd Command) {
+	r.commands[cmd.Name] = &cmd
+}
+
+// Lookup возвращает команду по имени
+func (r *Router) Lookup(name string) (*Command, bool) {
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// Dispatch вызывает обработчик команды из сообщения
+func (r *Router) Dispatch(msg *tgbotapi.Message) {
+	cmd, ok := r.commands[strings.ToLower(msg.Command())]
+	if !ok {
+		r.notFound(msg)
+		return
+	}
+
+	handler := cmd.Handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](cmd, handler)
+	}
+	handler(msg)
+}
+
+// Commands возвращает команды, доступные роли, в алфавитном порядке
+func (r *Router) Commands(role models.Role) []*Command {
+	var cmds []*Command
+	for _, cmd := range r.commands {
+		if role.AtLeast(cmd.Role) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// HelpText строит текст /help для роли
+func (r *Router) HelpText(role models.Role) string {
+	var sb strings.Builder
+	sb.WriteString("📚 Доступные команды:")
+	for _, cmd := range r.Commands(role) {
+		sb.WriteString("\n" + cmd.Usage() + " - " + cmd.Description)
+	}
+	return sb.String()
+}
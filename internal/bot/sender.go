@@ -0,0 +1,228 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const maxSendAttempts = 4
+
+// RateLimits задает ограничения Telegram на исходящие сообщения
+type RateLimits struct {
+	// Global — сообщений в секунду на весь бот
+	Global int
+	// ChatInterval — минимальный интервал между сообщениями в один чат
+	ChatInterval time.Duration
+	// GroupPerMinute — сообщений в минуту в одну группу
+	GroupPerMinute int
+}
+
+// DefaultRateLimits соответствуют лимитам из документации Bot API
+var DefaultRateLimits = RateLimits{
+	Global:         30,
+	ChatInterval:   time.Second,
+	GroupPerMinute: 20,
+}
+
+// Sender — общая очередь исходящих сообщений. Каждый Send ждет своего слота
+// с учетом глобального лимита и лимита чата, при ответе 429 повторяет запрос
+// через retry_after и возвращает вызывающему итоговую ошибку
+type Sender struct {
+	api    Messenger
+	limits RateLimits
+
+	mu         sync.Mutex
+	globalNext time.Time
+	chats      map[int64]*chatSlots
+}
+
+// chatSlots хранит расписание отправок в один чат
+type chatSlots struct {
+	next time.Time
+	// recent — время последних отправок, для поминутного лимита групп
+	recent []time.Time
+}
+
+// NewSender оборачивает api очередью с ограничением скорости
+func NewSender(api Messenger, limits RateLimits) *Sender {
+	return &Sender{
+		api:    api,
+		limits: limits,
+		chats:  make(map[int64]*chatSlots),
+	}
+}
+
+// Send отправляет сообщение, дождавшись разрешенного лимитами момента
+func (s *Sender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	err := s.queued(chattableChatID(c), func() (err error) {
+		msg, err = s.api.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+// queued выполняет отправку в чат chatID в свой слот и повторяет ее после
+// ответа 429
+func (s *Sender) queued(chatID int64, send func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		time.Sleep(s.reserve(chatID))
+
+		err = send()
+		if err == nil {
+			return nil
+		}
+
+		retryAfter, ok := retryAfter(err)
+		if !ok {
+			return err
+		}
+
+		log.Printf("Telegram flood limit for chat %d, retry after %v (attempt %d)", chatID, retryAfter, attempt)
+		s.penalize(chatID, retryAfter)
+	}
+
+	return fmt.Errorf("message to chat %d not sent after %d attempts: %w", chatID, maxSendAttempts, err)
+}
+
+// AnswerCallbackQuery не является сообщением и отправляется без очереди
+func (s *Sender) AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	return s.api.AnswerCallbackQuery(config)
+}
+
+// MakeRequest передает служебные вызовы Bot API без очереди. Методы
+// send* (например, sendMediaGroup) и edit* (например, editMessageMedia)
+// меняют сообщения в чате и проходят через очередь, как и Send
+func (s *Sender) MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error) {
+	if !strings.HasPrefix(endpoint, "send") && !strings.HasPrefix(endpoint, "edit") {
+		return s.api.MakeRequest(endpoint, params)
+	}
+
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	var resp tgbotapi.APIResponse
+	err := s.queued(chatID, func() (err error) {
+		resp, err = s.api.MakeRequest(endpoint, params)
+		return err
+	})
+	return resp, err
+}
+
+// reserve бронирует ближайший свободный слот для чата и возвращает,
+// сколько до него осталось ждать
+func (s *Sender) reserve(chatID int64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	at := now
+	if s.globalNext.After(at) {
+		at = s.globalNext
+	}
+
+	chat := s.chats[chatID]
+	if chat == nil {
+		chat = &chatSlots{}
+		s.chats[chatID] = chat
+	}
+	if chatID != 0 && chat.next.After(at) {
+		at = chat.next
+	}
+
+	if isGroupChat(chatID) && s.limits.GroupPerMinute > 0 {
+		chat.recent = dropBefore(chat.recent, at.Add(-time.Minute))
+		if len(chat.recent) >= s.limits.GroupPerMinute {
+			// Ждем, пока самая старая отправка выйдет из минутного окна
+			oldest := chat.recent[len(chat.recent)-s.limits.GroupPerMinute]
+			if free := oldest.Add(time.Minute); free.After(at) {
+				at = free
+			}
+		}
+		chat.recent = append(chat.recent, at)
+	}
+
+	if s.limits.Global > 0 {
+		s.globalNext = at.Add(time.Second / time.Duration(s.limits.Global))
+	}
+	if chatID != 0 {
+		chat.next = at.Add(s.limits.ChatInterval)
+	}
+
+	return at.Sub(now)
+}
+
+// penalize откладывает следующие отправки в чат на время, указанное Telegram
+func (s *Sender) penalize(chatID int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until := time.Now().Add(d)
+	chat := s.chats[chatID]
+	if chat.next.Before(until) {
+		chat.next = until
+	}
+	if chatID == 0 && s.globalNext.Before(until) {
+		s.globalNext = until
+	}
+}
+
+// retryAfter извлекает retry_after из ответа 429
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return time.Duration(apiErr.RetryAfter) * time.Second, true
+	}
+	return 0, false
+}
+
+func dropBefore(times []time.Time, t time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(t) {
+		i++
+	}
+	return times[i:]
+}
+
+func isGroupChat(chatID int64) bool {
+	return chatID < 0
+}
+
+// chattableChatID возвращает чат, в который уходит сообщение, или 0,
+// если тип конфигурации неизвестен
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case tgbotapi.VideoConfig:
+		return m.ChatID
+	case tgbotapi.AnimationConfig:
+		return m.ChatID
+	case tgbotapi.PhotoConfig:
+		return m.ChatID
+	case tgbotapi.DocumentConfig:
+		return m.ChatID
+	case tgbotapi.VideoNoteConfig:
+		return m.ChatID
+	case tgbotapi.MediaGroupConfig:
+		return m.ChatID
+	case tgbotapi.ForwardConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageCaptionConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return m.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return m.ChatID
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"tg-video-bot/internal/tgtest"
+)
+
+// TestSenderQueuesEditRequests проверяет, что editMessageMedia, который
+// вызывается через MakeRequest, повторяется после 429, а служебные методы
+// уходят без очереди
+func TestSenderQueuesEditRequests(t *testing.T) {
+	srv := tgtest.NewServer()
+	defer srv.Close()
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSender(api, DefaultRateLimits)
+
+	params := url.Values{}
+	params.Set("chat_id", "555")
+	params.Set("message_id", "1")
+	params.Set("media", `{"type":"video","media":"vid1"}`)
+
+	srv.FailNext("editMessageMedia", 1)
+	start := time.Now()
+	if _, err := s.MakeRequest("editMessageMedia", params); err != nil {
+		t.Fatalf("editMessageMedia after 429: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("editMessageMedia retried after %v, want no earlier than retry_after", elapsed)
+	}
+	if reqs := srv.Requests("editMessageMedia"); len(reqs) != 1 {
+		t.Fatalf("got %d editMessageMedia requests, want 1", len(reqs))
+	}
+
+	srv.FailNext("answerInlineQuery", 1)
+	if _, err := s.MakeRequest("answerInlineQuery", url.Values{}); err == nil {
+		t.Fatal("answerInlineQuery was retried, want the 429 returned as is")
+	}
+}
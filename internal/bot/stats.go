@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// statsTopLimit — длина каждого топа в отчете
+	statsTopLimit = 5
+	// statsMaxRows — сколько последних дней или недель показывать в динамике
+	statsMaxRows = 14
+	// statsDailyDays — до какой длины периода динамика строится по дням
+	statsDailyDays = 31
+)
+
+// statsPeriod — период отчета /stats. Days == 0 означает все время
+type statsPeriod struct {
+	Title string
+	Days  int
+}
+
+// parseStatsPeriod разбирает аргумент /stats: day, week, month, all или Nd
+func parseStatsPeriod(arg string) (statsPeriod, bool) {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "", "week", "неделя":
+		return statsPeriod{Title: "неделю", Days: 7}, true
+	case "day", "день", "сутки":
+		return statsPeriod{Title: "сутки", Days: 1}, true
+	case "month", "месяц":
+		return statsPeriod{Title: "30 дней", Days: 30}, true
+	case "all", "все", "всё":
+		return statsPeriod{Title: "все время"}, true
+	}
+
+	// Число дней: 7d или 7 d
+	days, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(arg)), "d")))
+	if err != nil || days <= 0 {
+		return statsPeriod{}, false
+	}
+	return statsPeriod{Title: fmt.Sprintf("%d дн.", days), Days: days}, true
+}
+
+// Since возвращает начало периода
+func (p statsPeriod) Since(now time.Time) time.Time {
+	if p.Days == 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -p.Days)
+}
+
+// HandleStatsCommand обрабатывает /stats [период]
+func (b *Bot) HandleStatsCommand(msg *tgbotapi.Message) {
+	period, ok := parseStatsPeriod(msg.CommandArguments())
+	if !ok {
+		b.SendMessage(msg.Chat.ID, "Используйте: /stats [day|week|month|all|Nd]")
+		return
+	}
+
+	stats, err := b.Store.GetStats(period.Since(time.Now()), statsTopLimit)
+	if err != nil {
+		log.Printf("Failed to get stats: %v", err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка получения статистики")
+		return
+	}
+
+	b.SendMessage(msg.Chat.ID, formatStats(period, stats))
+}
+
+// formatStats строит текст отчета
+func formatStats(period statsPeriod, stats models.Stats) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 Статистика за %s\n\n", period.Title)
+	fmt.Fprintf(&sb, "Видео: %d, тегов: %d\n", stats.TotalVideos, stats.TotalTags)
+	fmt.Fprintf(&sb, "Чатов получали видео: %d\n", stats.TotalChats)
+	fmt.Fprintf(&sb, "Отправлено за период: %d, активных чатов: %d\n", stats.Sent, stats.ActiveChats)
+
+	if len(stats.PerDay) > 0 {
+		rows := stats.PerDay
+		title := "По дням"
+		if period.Days == 0 || period.Days > statsDailyDays {
+			rows = groupByWeek(rows)
+			title = "По неделям"
+		}
+		if len(rows) > statsMaxRows {
+			rows = rows[len(rows)-statsMaxRows:]
+		}
+		sb.WriteString("\n📅 " + title + ":\n")
+		for _, d := range rows {
+			fmt.Fprintf(&sb, "%s: %d\n", d.Day, d.Count)
+		}
+	}
+
+	if len(stats.TopVideos) > 0 {
+		sb.WriteString("\n🎬 Популярные видео:\n")
+		for _, v := range stats.TopVideos {
+			fmt.Fprintf(&sb, "ID %d %s— %d\n", v.VideoID, shortCaption(v.Caption), v.Count)
+		}
+	}
+
+	if len(stats.TopTags) > 0 {
+		sb.WriteString("\n🏷 Теги отправленных видео:\n")
+		for _, t := range stats.TopTags {
+			fmt.Fprintf(&sb, "#%s — %d\n", t.Tag, t.Count)
+		}
+	}
+
+	if len(stats.TopRequests) > 0 {
+		sb.WriteString("\n🔍 Поиск по тегам:\n")
+		for _, t := range stats.TopRequests {
+			fmt.Fprintf(&sb, "#%s — %d\n", t.Tag, t.Count)
+		}
+	}
+
+	if len(stats.TopChats) > 0 {
+		sb.WriteString("\n💬 Активные чаты:\n")
+		for _, c := range stats.TopChats {
+			fmt.Fprintf(&sb, "%d — %d отправок, просмотрено %d из %d\n", c.ChatID, c.Sent, c.Seen, stats.TotalVideos)
+		}
+	}
+
+	return sb.String()
+}
+
+// groupByWeek суммирует дневные счетчики по неделям, начиная с понедельника
+func groupByWeek(days []models.DayCount) []models.DayCount {
+	var weeks []models.DayCount
+	for _, d := range days {
+		day, err := time.Parse("2006-01-02", d.Day)
+		if err != nil {
+			continue
+		}
+		offset := (int(day.Weekday()) + 6) % 7
+		week := day.AddDate(0, 0, -offset).Format("2006-01-02")
+
+		if n := len(weeks); n > 0 && weeks[n-1].Day == week {
+			weeks[n-1].Count += d.Count
+			continue
+		}
+		weeks = append(weeks, models.DayCount{Day: week, Count: d.Count})
+	}
+	return weeks
+}
+
+// shortCaption обрезает подпись видео для отчетов
+func shortCaption(caption string) string {
+	const maxLen = 30
+
+	caption = strings.Join(strings.Fields(caption), " ")
+	if caption == "" {
+		return ""
+	}
+	if runes := []rune(caption); len(runes) > maxLen {
+		caption = string(runes[:maxLen]) + "…"
+	}
+	return "(" + caption + ") "
+}
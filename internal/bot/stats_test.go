@@ -0,0 +1,33 @@
+package bot
+
+import "testing"
+
+func TestParseStatsPeriod(t *testing.T) {
+	tests := []struct {
+		arg  string
+		days int
+		ok   bool
+	}{
+		{"", 7, true},
+		{"week", 7, true},
+		{"День", 1, true},
+		{"month", 30, true},
+		{"all", 0, true},
+		{"14d", 14, true},
+		{"14D", 14, true},
+		{"14 d", 14, true},
+		{" 3d ", 3, true},
+		{"10", 10, true},
+		{"0d", 0, false},
+		{"-5d", 0, false},
+		{"d", 0, false},
+		{"year", 0, false},
+	}
+
+	for _, tt := range tests {
+		period, ok := parseStatsPeriod(tt.arg)
+		if ok != tt.ok || (ok && period.Days != tt.days) {
+			t.Errorf("parseStatsPeriod(%q) = %d days, %v; want %d days, %v", tt.arg, period.Days, ok, tt.days, tt.ok)
+		}
+	}
+}
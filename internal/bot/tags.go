@@ -0,0 +1,305 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/database"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/pkg/utilities"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// callbackTagsPrefix — кнопки листания списка /tags
+	callbackTagsPrefix = "tags:"
+	tagsPageSize       = 30
+)
+
+// HandleTagsCommand обрабатывает /tags [страница]: список тегов с числом видео
+func (b *Bot) HandleTagsCommand(msg *tgbotapi.Message) {
+	page := 0
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			b.SendMessage(msg.Chat.ID, "Используйте: /tags [страница]")
+			return
+		}
+		page = n - 1
+	}
+
+	text, markup, err := b.tagsPage(page)
+	if err != nil {
+		log.Printf("Failed to list tags: %v", err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка получения тегов")
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	if markup != nil {
+		reply.ReplyMarkup = markup
+	}
+	if _, err := b.API.Send(reply); err != nil {
+		log.Printf("Failed to send tags to chat %d: %v", msg.Chat.ID, err)
+	}
+}
+
+// HandleTagsCallback листает список тегов в том же сообщении
+func (b *Bot) HandleTagsCallback(query *tgbotapi.CallbackQuery) {
+	// Кнопки видны всем в чате, поэтому права проверяются при нажатии
+	if !b.can(int64(query.From.ID), models.RoleModerator) {
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "❌ Недостаточно прав"))
+		return
+	}
+	page, err := strconv.Atoi(strings.TrimPrefix(query.Data, callbackTagsPrefix))
+	if err != nil || page < 0 {
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+
+	text, markup, err := b.tagsPage(page)
+	if err != nil {
+		log.Printf("Failed to list tags: %v", err)
+		b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "❌ Ошибка получения тегов"))
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	edit.ReplyMarkup = markup
+	if _, err := b.API.Send(edit); err != nil {
+		log.Printf("Failed to edit tags in chat %d: %v", query.Message.Chat.ID, err)
+	}
+	b.API.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, ""))
+}
+
+// tagsPage строит текст страницы списка тегов и кнопки листания. Страница
+// за концом списка заменяется последней
+func (b *Bot) tagsPage(page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	tags, total, err := b.Store.ListTags(tagsPageSize, page*tagsPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return "🏷 Тегов пока нет", nil, nil
+	}
+	pages := (total + tagsPageSize - 1) / tagsPageSize
+	if page >= pages {
+		page = pages - 1
+		if tags, total, err = b.Store.ListTags(tagsPageSize, page*tagsPageSize); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "🏷 Теги: %d\n\n", total)
+	for _, tc := range tags {
+		fmt.Fprintf(&text, "#%s — %d\n", tc.Tag, tc.Count)
+	}
+	if pages == 1 {
+		return text.String(), nil, nil
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️", callbackTagsPrefix+strconv.Itoa(page-1)))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d / %d", page+1, pages), callbackNoop))
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶️", callbackTagsPrefix+strconv.Itoa(page+1)))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(row)
+	return text.String(), &markup, nil
+}
+
+// HandleRenameTagCommand обрабатывает /rename_tag старый новый
+func (b *Bot) HandleRenameTagCommand(msg *tgbotapi.Message) {
+	args := tagArgs(msg.CommandArguments())
+	if len(args) != 2 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /rename_tag старый новый")
+		return
+	}
+	found, ok := b.lookupTags(msg.Chat.ID, args[:1])
+	if !ok {
+		return
+	}
+	oldName, newName := found[0], args[1]
+
+	err := b.Store.RenameTag(oldName, newName)
+	switch {
+	case errors.Is(err, database.ErrTagNotFound):
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Тег #%s не найден", oldName))
+	case errors.Is(err, database.ErrTagExists):
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Тег #%s уже есть. Объединить теги: /merge_tags %s %s", newName, oldName, newName))
+	case err != nil:
+		log.Printf("Failed to rename tag %q: %v", oldName, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка переименования тега")
+	default:
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ Тег #%s переименован в #%s", oldName, newName))
+	}
+}
+
+// HandleMergeTagsCommand обрабатывает /merge_tags откуда... куда: видео
+// всех тегов, кроме последнего, получают последний, а сами теги удаляются
+func (b *Bot) HandleMergeTagsCommand(msg *tgbotapi.Message) {
+	args := tagArgs(msg.CommandArguments())
+	if len(args) < 2 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /merge_tags тег... куда\nПример: /merge_tags котеки котэ котики")
+		return
+	}
+	tags, ok := b.lookupTags(msg.Chat.ID, args[:len(args)-1])
+	if !ok {
+		return
+	}
+	// Тег into может еще не существовать, тогда MergeTags его создаст
+	into := args[len(args)-1]
+	canonical, ok, err := b.Store.LookupTag(into)
+	if err != nil {
+		log.Printf("Failed to look up tag %q: %v", into, err)
+	}
+	if ok {
+		into = canonical
+	}
+	var from []string
+	for _, tag := range tags {
+		if tag != into {
+			from = append(from, tag)
+		}
+	}
+	if len(from) == 0 {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Теги уже объединены в #%s", into))
+		return
+	}
+
+	data := map[string]string{"from": strings.Join(from, " "), "into": into}
+	b.startDialog(msg, stateMergeTagsConfirm, data,
+		fmt.Sprintf("Объединить #%s в #%s? Теги #%s будут удалены",
+			strings.Join(from, ", #"), into, strings.Join(from, ", #")),
+		confirmKeyboard())
+}
+
+// mergeTags объединяет подтвержденные теги и сообщает результат
+func (b *Bot) mergeTags(chatID int64, from []string, into string) {
+	moved, err := b.Store.MergeTags(from, into)
+	if errors.Is(err, database.ErrTagNotFound) {
+		b.SendMessage(chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to merge tags %v into %q: %v", from, into, err)
+		b.SendMessage(chatID, "❌ Ошибка объединения тегов")
+		return
+	}
+	b.SendMessage(chatID, fmt.Sprintf("✅ Теги объединены в #%s, добавлен к видео: %d", into, moved))
+}
+
+// HandleDeleteTagCommand спрашивает подтверждение перед удалением тега
+func (b *Bot) HandleDeleteTagCommand(msg *tgbotapi.Message) {
+	args := tagArgs(msg.CommandArguments())
+	if len(args) != 1 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /delete_tag тег")
+		return
+	}
+
+	found, ok := b.lookupTags(msg.Chat.ID, args)
+	if !ok {
+		return
+	}
+
+	data := map[string]string{"tag": found[0]}
+	b.startDialog(msg, stateDeleteTagConfirm, data,
+		fmt.Sprintf("Удалить тег #%s? Он будет снят со всех видео", found[0]), confirmKeyboard())
+}
+
+// deleteTag удаляет подтвержденный тег и сообщает результат
+func (b *Bot) deleteTag(chatID int64, tag string) {
+	videos, err := b.Store.DeleteTag(tag)
+	if errors.Is(err, database.ErrTagNotFound) {
+		b.SendMessage(chatID, fmt.Sprintf("❌ Тег #%s не найден", tag))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete tag %q: %v", tag, err)
+		b.SendMessage(chatID, "❌ Ошибка удаления тега")
+		return
+	}
+	b.SendMessage(chatID, fmt.Sprintf("✅ Тег #%s удален, снят с видео: %d", tag, videos))
+}
+
+// HandleRemoveTagsCommand обрабатывает /remove_tags ID теги...
+func (b *Bot) HandleRemoveTagsCommand(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) < 2 {
+		b.SendMessage(msg.Chat.ID, "Используйте: /remove_tags ID теги...")
+		return
+	}
+	videoID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.SendMessage(msg.Chat.ID, "❌ Неверный ID видео")
+		return
+	}
+	exists, err := b.Store.VideoExists(videoID)
+	if err != nil {
+		log.Printf("Failed to check video %d: %v", videoID, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка проверки видео")
+		return
+	}
+	if !exists {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Видео с ID %d не найдено", videoID))
+		return
+	}
+
+	tags := tagArgs(strings.Join(args[1:], " "))
+	removed, err := b.Store.RemoveTagsFromVideo(videoID, tags)
+	if err != nil {
+		log.Printf("Failed to remove tags from video %d: %v", videoID, err)
+		b.SendMessage(msg.Chat.ID, "❌ Ошибка удаления тегов")
+		return
+	}
+	if removed == 0 {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("У видео ID %d нет таких тегов", videoID))
+		return
+	}
+	b.SendMessage(msg.Chat.ID, fmt.Sprintf("✅ С видео ID %d снято тегов: %d", videoID, removed))
+}
+
+// lookupTags заменяет имена и псевдонимы тегов каноничными именами без
+// повторов. Если какого-то тега нет, отвечает в чат и возвращает false
+func (b *Bot) lookupTags(chatID int64, names []string) ([]string, bool) {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		canonical, ok, err := b.Store.LookupTag(name)
+		if err != nil {
+			log.Printf("Failed to look up tag %q: %v", name, err)
+			b.SendMessage(chatID, "❌ Ошибка поиска тега")
+			return nil, false
+		}
+		if !ok {
+			b.SendMessage(chatID, b.tagNotFound(name))
+			return nil, false
+		}
+		if !seen[canonical] {
+			seen[canonical] = true
+			tags = append(tags, canonical)
+		}
+	}
+	return tags, true
+}
+
+// tagArgs разбирает теги из аргументов команды, приводит их к виду, в
+// котором теги хранятся, и убирает повторы
+func tagArgs(text string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(text) {
+		tag := utilities.NormalizeTag(field)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Update — апдейт Telegram вместе с полями Bot API, которые tgbotapi v4
+// не разбирает. Апдейты декодируются из исходного JSON и в режиме polling,
+// и в режиме webhook
+type Update struct {
+	tgbotapi.Update
+	// Extra — дополнительные поля сообщения из Update.Message
+	Extra MessageExtra
+}
+
+// MessageExtra — поля Message, которых нет в tgbotapi v4
+type MessageExtra struct {
+	MediaGroupID    string                   `json:"media_group_id"`
+	CaptionEntities []tgbotapi.MessageEntity `json:"caption_entities"`
+	Video           *FileExtra               `json:"video"`
+	Animation       *FileExtra               `json:"animation"`
+	Document        *FileExtra               `json:"document"`
+	VideoNote       *FileExtra               `json:"video_note"`
+	Photo           []FileExtra              `json:"photo"`
+}
+
+// FileExtra — поля файла, которых нет в tgbotapi v4
+type FileExtra struct {
+	FileUniqueID string `json:"file_unique_id"`
+}
+
+// fileUniqueID возвращает file_unique_id или пустую строку
+func (f *FileExtra) fileUniqueID() string {
+	if f == nil {
+		return ""
+	}
+	return f.FileUniqueID
+}
+
+// UpdatesChannel — канал апдейтов, который обрабатывает Bot.Run
+type UpdatesChannel <-chan Update
+
+// UnmarshalJSON разбирает апдейт средствами tgbotapi и дочитывает
+// поля, которых библиотека не знает
+func (u *Update) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Update); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Message *MessageExtra `json:"message"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Message != nil {
+		u.Extra = *raw.Message
+	}
+	return nil
+}
+
+// getUpdates вызывает getUpdates напрямую, чтобы сохранить поля,
+// которые теряет api.GetUpdates
+func getUpdates(api *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) ([]Update, error) {
+	v := url.Values{}
+	if config.Offset != 0 {
+		v.Add("offset", strconv.Itoa(config.Offset))
+	}
+	if config.Limit > 0 {
+		v.Add("limit", strconv.Itoa(config.Limit))
+	}
+	if config.Timeout > 0 {
+		v.Add("timeout", strconv.Itoa(config.Timeout))
+	}
+
+	resp, err := api.MakeRequest("getUpdates", v)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []Update
+	if err := json.Unmarshal(resp.Result, &updates); err != nil {
+		return nil, fmt.Errorf("failed to decode updates: %v", err)
+	}
+	return updates, nil
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"tg-video-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// HandleVideoInfoCommand обрабатывает /video_info <ID>
+func (b *Bot) HandleVideoInfoCommand(msg *tgbotapi.Message) {
+	videoID, err := strconv.ParseInt(strings.TrimSpace(msg.CommandArguments()), 10, 64)
+	if err != nil {
+		b.SendMessage(msg.Chat.ID, "Используйте: /video_info [ID видео]")
+		return
+	}
+
+	video, err := b.Store.GetVideoByID(videoID)
+	if err != nil {
+		b.SendMessage(msg.Chat.ID, fmt.Sprintf("❌ Видео с ID %d не найдено", videoID))
+		return
+	}
+
+	b.SendMessage(msg.Chat.ID, formatVideoInfo(video))
+}
+
+// formatVideoInfo описывает видео со всеми сохраненными метаданными
+func formatVideoInfo(v models.Video) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🎬 %s ID %d\n", v.Type.Label(), v.ID)
+	if v.Caption != "" {
+		fmt.Fprintf(&sb, "Описание: %s\n", v.Caption)
+	}
+	if len(v.Tags) > 0 {
+		fmt.Fprintf(&sb, "Теги: %s\n", strings.Join(v.Tags, ", "))
+	}
+
+	sb.WriteString("\n")
+	if v.Duration > 0 {
+		fmt.Fprintf(&sb, "Длительность: %s\n", formatDuration(v.Duration))
+	}
+	if v.Width > 0 && v.Height > 0 {
+		fmt.Fprintf(&sb, "Разрешение: %dx%d\n", v.Width, v.Height)
+	}
+	if v.MimeType != "" {
+		fmt.Fprintf(&sb, "Формат: %s\n", v.MimeType)
+	}
+	if v.FileSize > 0 {
+		fmt.Fprintf(&sb, "Размер: %s\n", formatSize(v.FileSize))
+	}
+
+	if v.UploaderID != 0 {
+		fmt.Fprintf(&sb, "\nЗагрузил: %d\n", v.UploaderID)
+	}
+	if !v.CreatedAt.IsZero() {
+		fmt.Fprintf(&sb, "Добавлено: %s\n", v.CreatedAt.Format("2006-01-02 15:04"))
+	}
+
+	fmt.Fprintf(&sb, "\nfile_id: %s", v.FileID)
+	if v.FileUniqueID != "" {
+		fmt.Fprintf(&sb, "\nfile_unique_id: %s", v.FileUniqueID)
+	}
+	if v.ThumbFileID != "" {
+		fmt.Fprintf(&sb, "\nthumb: %s", v.ThumbFileID)
+	}
+
+	return sb.String()
+}
+
+// videoSummary — краткая строка для списков: тип, длительность, разрешение, размер
+func videoSummary(v models.Video) string {
+	parts := []string{v.Type.Label()}
+	if v.Duration > 0 {
+		parts = append(parts, formatDuration(v.Duration))
+	}
+	if v.Width > 0 && v.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%dx%d", v.Width, v.Height))
+	}
+	if v.FileSize > 0 {
+		parts = append(parts, formatSize(v.FileSize))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatDuration форматирует секунды как m:ss или h:mm:ss
+func formatDuration(seconds int) string {
+	h, m, s := seconds/3600, seconds%3600/60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// formatSize форматирует размер файла в КБ, МБ или ГБ
+func formatSize(bytes int64) string {
+	const unit = 1024
+	switch {
+	case bytes < unit:
+		return fmt.Sprintf("%d Б", bytes)
+	case bytes < unit*unit:
+		return fmt.Sprintf("%.1f КБ", float64(bytes)/unit)
+	case bytes < unit*unit*unit:
+		return fmt.Sprintf("%.1f МБ", float64(bytes)/(unit*unit))
+	}
+	return fmt.Sprintf("%.1f ГБ", float64(bytes)/(unit*unit*unit))
+}
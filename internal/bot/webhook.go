@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookServer принимает апдейты от Telegram по HTTP
+type webhookServer struct {
+	cfg     WebhookConfig
+	server  *http.Server
+	updates chan Update
+
+	// mu не дает закрыть updates, пока ServeHTTP в него пишет; done
+	// прерывает такие записи при остановке
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWebhookServer(cfg WebhookConfig) (*webhookServer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_URL: %v", err)
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	wh := &webhookServer{
+		cfg:     cfg,
+		updates: make(chan Update, 100),
+		done:    make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, wh)
+	wh.server = &http.Server{Addr: cfg.Listen, Handler: mux}
+
+	return wh, nil
+}
+
+// Updates возвращает канал апдейтов; он закрывается после Shutdown или
+// ошибки ListenAndServe
+func (wh *webhookServer) Updates() UpdatesChannel {
+	return wh.updates
+}
+
+// ListenAndServe блокируется до остановки сервера
+func (wh *webhookServer) ListenAndServe() error {
+	log.Printf("Webhook listening on %s", wh.cfg.Listen)
+
+	var err error
+	if wh.cfg.TLSCert != "" && wh.cfg.TLSKey != "" {
+		err = wh.server.ListenAndServeTLS(wh.cfg.TLSCert, wh.cfg.TLSKey)
+	} else {
+		err = wh.server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		// Канал закроет Shutdown, когда дождется запросов
+		return nil
+	}
+	wh.closeUpdates()
+	return err
+}
+
+// Shutdown перестает принимать новые апдейты, дожидается уже пришедших
+// запросов и закрывает канал апдейтов. Запросы, не успевшие до ctx,
+// получают 503, и Telegram доставит их повторно
+func (wh *webhookServer) Shutdown(ctx context.Context) error {
+	err := wh.server.Shutdown(ctx)
+	wh.closeUpdates()
+	return err
+}
+
+// closeUpdates закрывает канал апдейтов, когда в него никто не пишет
+func (wh *webhookServer) closeUpdates() {
+	wh.closeOnce.Do(func() {
+		close(wh.done)
+		wh.mu.Lock()
+		defer wh.mu.Unlock()
+		wh.closed = true
+		close(wh.updates)
+	})
+}
+
+func (wh *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if wh.cfg.SecretToken != "" {
+		got := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(wh.cfg.SecretToken)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var update Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	if wh.closed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	// Telegram повторит доставку, если не получит 200
+	select {
+	case wh.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case <-wh.done:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// setWebhook регистрирует webhook в Telegram, при необходимости
+// загружая самоподписанный сертификат
+func setWebhook(api *tgbotapi.BotAPI, cfg WebhookConfig) error {
+	params := map[string]string{"url": cfg.URL}
+	if cfg.SecretToken != "" {
+		params["secret_token"] = cfg.SecretToken
+	}
+
+	var err error
+	if cfg.UploadCert && cfg.TLSCert != "" {
+		_, err = api.UploadFile("setWebhook", params, "certificate", cfg.TLSCert)
+	} else {
+		values := url.Values{}
+		for k, v := range params {
+			values.Set(k, v)
+		}
+		_, err = api.MakeRequest("setWebhook", values)
+	}
+	if err != nil {
+		return fmt.Errorf("setWebhook failed: %v", err)
+	}
+
+	return nil
+}
+
+// deleteWebhook снимает webhook, если он был установлен: пока он активен,
+// getUpdates не возвращает апдейты
+func deleteWebhook(api *tgbotapi.BotAPI) error {
+	info, err := api.GetWebhookInfo()
+	if err != nil {
+		return fmt.Errorf("getWebhookInfo failed: %v", err)
+	}
+	if !info.IsSet() {
+		return nil
+	}
+
+	log.Printf("Removing webhook %s to switch to long polling", info.URL)
+	if _, err := api.MakeRequest("deleteWebhook", url.Values{}); err != nil {
+		return fmt.Errorf("deleteWebhook failed: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"tg-video-bot/pkg/utilities"
+)
+
+// AddTagAlias делает alias псевдонимом тега tag. Псевдоним, который уже
+// вел к другому тегу, переносится
+func (r *VideoRepository) AddTagAlias(alias, tag string) error {
+	alias = utilities.NormalizeTag(alias)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, _, ok, err := lookupTag(tx, tag)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, utilities.NormalizeTag(tag))
+	}
+
+	// Псевдоним не может совпадать с тегом: тег нашелся бы раньше
+	if _, err := tagID(tx, alias); err == nil {
+		return fmt.Errorf("%w: %s", ErrTagExists, alias)
+	} else if !errors.Is(err, ErrTagNotFound) {
+		return err
+	}
+
+	if err := r.saveAlias(tx, alias, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTagAlias удаляет псевдоним
+func (r *VideoRepository) DeleteTagAlias(alias string) error {
+	alias = utilities.NormalizeTag(alias)
+	result, err := r.db.Exec("DELETE FROM tag_aliases WHERE alias = ?", alias)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления псевдонима: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+	}
+	return nil
+}
+
+// ListTagAliases возвращает все псевдонимы, сгруппированные по тегам
+func (r *VideoRepository) ListTagAliases() ([]models.TagAlias, error) {
+	rows, err := r.db.Query(`
+		SELECT a.alias, t.name
+		FROM tag_aliases a
+		JOIN tags t ON t.id = a.tag_id
+		ORDER BY t.name, a.alias`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса псевдонимов: %v", err)
+	}
+	defer rows.Close()
+
+	var aliases []models.TagAlias
+	for rows.Next() {
+		var a models.TagAlias
+		if err := rows.Scan(&a.Alias, &a.Tag); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования псевдонима: %v", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// LookupTag возвращает каноничное имя тега по имени или псевдониму
+func (r *VideoRepository) LookupTag(name string) (string, bool, error) {
+	_, canonical, ok, err := lookupTag(r.db, name)
+	return canonical, ok, err
+}
+
+// ResolveTag возвращает каноничное имя тега по имени, псевдониму или
+// другой записи того же слова
+func (r *VideoRepository) ResolveTag(name string) (string, bool, error) {
+	_, canonical, ok, err := resolveTag(r.db, name)
+	return canonical, ok, err
+}
+
+// resolveQuery подставляет в запрос каноничные имена тегов. Неизвестные
+// теги остаются как есть и просто ничего не найдут
+func (r *VideoRepository) resolveQuery(query tagquery.Expr) (tagquery.Expr, error) {
+	if query == nil {
+		return nil, nil
+	}
+	var err error
+	resolved := tagquery.MapTags(query, func(tag string) string {
+		if err != nil {
+			return tag
+		}
+		canonical, ok, e := r.ResolveTag(tag)
+		if e != nil {
+			err = e
+		}
+		if !ok {
+			return tag
+		}
+		return canonical
+	})
+	return resolved, err
+}
+
+// lookupTag ищет тег по имени, затем среди псевдонимов. Так теги ищутся
+// при сохранении: новое слово, совпавшее с тегом только транслитерацией,
+// становится отдельным тегом
+func lookupTag(q queryRower, name string) (int64, string, bool, error) {
+	name = utilities.NormalizeTag(name)
+	if name == "" {
+		return 0, "", false, nil
+	}
+
+	var id int64
+	var canonical string
+	for _, query := range []string{
+		"SELECT id, name FROM tags WHERE name = ?",
+		"SELECT t.id, t.name FROM tag_aliases a JOIN tags t ON t.id = a.tag_id WHERE a.alias = ?",
+	} {
+		err := q.QueryRow(query, name).Scan(&id, &canonical)
+		if err == nil {
+			return id, canonical, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, "", false, fmt.Errorf("ошибка запроса тега: %v", err)
+		}
+	}
+	return 0, "", false, nil
+}
+
+// resolveTag ищет тег для поиска: как lookupTag, а если не нашел — по
+// ключу транслитерации (utilities.TranslitKey), который хранится в
+// translit_key тегов и псевдонимов
+func resolveTag(q queryRower, name string) (int64, string, bool, error) {
+	id, canonical, ok, err := lookupTag(q, name)
+	if ok || err != nil {
+		return id, canonical, ok, err
+	}
+	name = utilities.NormalizeTag(name)
+	if name == "" {
+		return 0, "", false, nil
+	}
+
+	// При нескольких совпадениях выигрывает самый старый тег
+	key := utilities.TranslitKey(name)
+	err = q.QueryRow(`
+		SELECT id, name FROM (
+			SELECT id, name FROM tags WHERE translit_key = ?
+			UNION ALL
+			SELECT t.id, t.name FROM tag_aliases a JOIN tags t ON t.id = a.tag_id WHERE a.translit_key = ?
+		) found
+		ORDER BY id
+		LIMIT 1`,
+		key, key,
+	).Scan(&id, &canonical)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("ошибка запроса тега: %v", err)
+	}
+	return id, canonical, true, nil
+}
+
+// insertTag создает тег с уже нормализованным именем и возвращает его ID
+func insertTag(tx *sql.Tx, name string) (int64, error) {
+	result, err := tx.Exec("INSERT INTO tags (name, translit_key) VALUES (?, ?)", name, utilities.TranslitKey(name))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка добавления тега: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+// fillTranslitKeys заполняет translit_key существующих тегов и псевдонимов
+func fillTranslitKeys(tx *sql.Tx) error {
+	for _, table := range []struct{ name, column string }{
+		{"tags", "name"},
+		{"tag_aliases", "alias"},
+	} {
+		rows, err := tx.Query("SELECT " + table.column + " FROM " + table.name)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			names = append(names, name)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			_, err := tx.Exec("UPDATE "+table.name+" SET translit_key = ? WHERE "+table.column+" = ?",
+				utilities.TranslitKey(name), name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package database
+
+import "strings"
+
+// Dialect описывает различия SQL между поддерживаемыми СУБД
+type Dialect struct {
+	Name string
+	// InsertIgnore — начало INSERT, не падающего на дубликате ключа
+	InsertIgnore string
+	// Random — выражение для случайной сортировки
+	Random string
+	// MigrationsTable создает таблицу учета миграций
+	MigrationsTable string
+	Migrations      []Migration
+}
+
+var MySQL = Dialect{
+	Name:         "mysql",
+	InsertIgnore: "INSERT IGNORE",
+	Random:       "RAND()",
+	MigrationsTable: `
+		CREATE TABLE IF NOT EXISTS migrations (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL UNIQUE,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+	Migrations: migrations,
+}
+
+var SQLite = Dialect{
+	Name:         "sqlite",
+	InsertIgnore: "INSERT OR IGNORE",
+	Random:       "RANDOM()",
+	MigrationsTable: `
+		CREATE TABLE IF NOT EXISTS migrations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	Migrations: sqliteMigrations,
+}
+
+// Upsert строит INSERT в table, который при конфликте по ключу keys
+// обновляет остальные колонки из columns
+func (d Dialect) Upsert(table string, keys, columns []string) string {
+	isKey := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		isKey[k] = true
+	}
+
+	placeholders := make([]string, len(columns))
+	var sets []string
+	for i, c := range columns {
+		placeholders[i] = "?"
+		if isKey[c] {
+			continue
+		}
+		if d.Name == "sqlite" {
+			sets = append(sets, c+" = excluded."+c)
+		} else {
+			sets = append(sets, c+" = VALUES("+c+")")
+		}
+	}
+
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	if d.Name == "sqlite" {
+		return query + " ON CONFLICT(" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	return query + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
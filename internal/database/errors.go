@@ -0,0 +1,39 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/ncruces/go-sqlite3"
+)
+
+// mysqlDuplicateEntry — код ошибки MySQL ER_DUP_ENTRY
+const mysqlDuplicateEntry = 1062
+
+// Ошибки операций с тегами
+var (
+	ErrTagNotFound   = errors.New("тег не найден")
+	ErrTagExists     = errors.New("тег уже существует")
+	ErrAliasNotFound = errors.New("псевдоним не найден")
+)
+
+// DuplicateVideoError возвращается SaveVideo, если этот файл уже сохранен
+// (совпал file_unique_id или file_id)
+type DuplicateVideoError struct {
+	// VideoID — ID ранее сохраненного видео
+	VideoID int64
+}
+
+func (e *DuplicateVideoError) Error() string {
+	return fmt.Sprintf("видео уже сохранено с ID %d", e.VideoID)
+}
+
+// isUniqueViolation проверяет, что запрос нарушил уникальный ключ
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	return errors.Is(err, sqlite3.CONSTRAINT_UNIQUE)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"fmt"
+	"tg-video-bot/internal/models"
+	"time"
+)
+
+// ResetSentVideos очищает историю отправок чата
+func (r *VideoRepository) ResetSentVideos(chatID int64) (int, error) {
+	result, err := r.db.Exec("DELETE FROM sent_videos WHERE chat_id = ?", chatID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сброса истории: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сброса истории: %v", err)
+	}
+	return int(n), nil
+}
+
+// GetReplayVideos возвращает отправленные в чат видео по возрастанию
+// времени последнего просмотра
+func (r *VideoRepository) GetReplayVideos(chatID int64, seenBefore time.Time, limit int) ([]models.Video, error) {
+	where := "sv.chat_id = ?"
+	args := []interface{}{chatID}
+	if !seenBefore.IsZero() {
+		// Метки времени в базе хранятся в UTC
+		where += " AND sv.sent_at < ?"
+		args = append(args, seenBefore.UTC())
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+videoColumns+`
+		FROM sent_videos sv
+		JOIN media v ON v.id = sv.video_id
+		WHERE `+where+`
+		ORDER BY sv.sent_at, v.id
+		LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выбора видео для повтора: %v", err)
+	}
+	defer rows.Close()
+
+	var videos []models.Video
+	for rows.Next() {
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования видео: %v", err)
+		}
+		videos = append(videos, video)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadTags(videos); err != nil {
+		return nil, fmt.Errorf("ошибка получения тегов: %v", err)
+	}
+	return videos, nil
+}
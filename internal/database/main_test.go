@@ -0,0 +1,13 @@
+package database
+
+import (
+	"github.com/ncruces/go-sqlite3"
+	"github.com/tetratelabs/wazero"
+)
+
+// Тесты запускают SQLite в интерпретаторе wazero: компилятор wazero на
+// некоторых виртуальных машинах падает внутри sqlite3.wasm с «out of bounds
+// memory access». Интерпретатор медленнее, но стабилен
+func init() {
+	sqlite3.RuntimeConfig = wazero.NewRuntimeConfigInterpreter()
+}
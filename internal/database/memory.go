@@ -0,0 +1,1028 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"tg-video-bot/pkg/utilities"
+	"time"
+)
+
+// MemoryStore хранит видео в памяти процесса. Повторяет семантику
+// SQL-репозитория (уникальность file_id и file_unique_id, каскадное удаление,
+// история отправок)
+// и нужен для тестов и запуска без базы данных
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	lastVideoID int64
+	lastAlbumID int64
+	lastTagID   int64
+
+	videos    map[int64]models.Video
+	fileIDs   map[string]int64
+	uniqueIDs map[string]int64
+	tagIDs    map[string]int64
+	tagNames  map[int64]string
+	aliases   map[string]int64
+	videoTags map[int64]map[int64]struct{}
+	sent      map[int64]map[int64]time.Time
+
+	offsets   map[int64]int
+	processed map[int64]map[int]struct{}
+
+	users map[int64]models.UserRole
+
+	deliveries  []delivery
+	tagRequests []tagRequest
+
+	states map[int64]models.ChatState
+}
+
+type delivery struct {
+	chatID  int64
+	videoID int64
+	source  string
+	at      time.Time
+}
+
+type tagRequest struct {
+	chatID int64
+	tag    string
+	at     time.Time
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		videos:    make(map[int64]models.Video),
+		fileIDs:   make(map[string]int64),
+		uniqueIDs: make(map[string]int64),
+		tagIDs:    make(map[string]int64),
+		tagNames:  make(map[int64]string),
+		aliases:   make(map[string]int64),
+		videoTags: make(map[int64]map[int64]struct{}),
+		sent:      make(map[int64]map[int64]time.Time),
+		offsets:   make(map[int64]int),
+		processed: make(map[int64]map[int]struct{}),
+		users:     make(map[int64]models.UserRole),
+		states:    make(map[int64]models.ChatState),
+	}
+}
+
+// SaveVideo сохраняет видео
+func (s *MemoryStore) SaveVideo(video models.Video) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.findDuplicate(video); ok {
+		return 0, &DuplicateVideoError{VideoID: id}
+	}
+	return s.insertVideo(video), nil
+}
+
+// SaveAlbum сохраняет новые файлы альбома, пропуская уже известные
+func (s *MemoryStore) SaveAlbum(album models.Album) (models.Album, []int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fresh []models.Video
+	var duplicates []int64
+	for _, video := range album.Videos {
+		if id, ok := s.findDuplicate(video); ok {
+			duplicates = append(duplicates, id)
+			continue
+		}
+		fresh = append(fresh, video)
+	}
+	if len(fresh) == 0 {
+		return models.Album{}, duplicates, nil
+	}
+
+	s.lastAlbumID++
+	saved := models.Album{ID: s.lastAlbumID, Caption: album.Caption}
+	for _, video := range fresh {
+		video.AlbumID = saved.ID
+		video.ID = s.insertVideo(video)
+		saved.Videos = append(saved.Videos, video)
+	}
+	return saved, duplicates, nil
+}
+
+// GetAlbumVideos возвращает файлы альбома с тегами в порядке загрузки
+func (s *MemoryStore) GetAlbumVideos(albumID int64) ([]models.Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var videos []models.Video
+	for _, id := range s.sortedVideoIDs() {
+		if v := s.videos[id]; v.AlbumID == albumID {
+			v.Tags = s.videoTagNames(id)
+			videos = append(videos, v)
+		}
+	}
+	return videos, nil
+}
+
+// findDuplicate ищет сохраненное видео с тем же file_unique_id или file_id.
+// Вызывается под s.mu
+func (s *MemoryStore) findDuplicate(video models.Video) (int64, bool) {
+	if id, ok := s.uniqueIDs[video.FileUniqueID]; ok && video.FileUniqueID != "" {
+		return id, true
+	}
+	id, ok := s.fileIDs[video.FileID]
+	return id, ok
+}
+
+// insertVideo добавляет видео с тегами и возвращает его ID. Вызывается под s.mu
+func (s *MemoryStore) insertVideo(video models.Video) int64 {
+	s.lastVideoID++
+	video.ID = s.lastVideoID
+	if video.Type == "" {
+		video.Type = models.MediaVideo
+	}
+	if video.CreatedAt.IsZero() {
+		video.CreatedAt = time.Now()
+	}
+	tags := video.Tags
+	video.Tags = nil
+	s.videos[video.ID] = video
+	s.fileIDs[video.FileID] = video.ID
+	if video.FileUniqueID != "" {
+		s.uniqueIDs[video.FileUniqueID] = video.ID
+	}
+	s.addTags(video.ID, tags)
+
+	return video.ID
+}
+
+// GetVideoByID возвращает видео по его ID вместе с тегами
+func (s *MemoryStore) GetVideoByID(id int64) (models.Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	video, ok := s.videos[id]
+	if !ok {
+		return models.Video{}, fmt.Errorf("видео с ID %d не найдено", id)
+	}
+
+	video.Tags = s.videoTagNames(id)
+	return video, nil
+}
+
+// GetVideosByTag возвращает все видео с указанным тегом
+func (s *MemoryStore) GetVideosByTag(tag string) ([]models.Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tagID, ok := s.tagIDs[tag]
+	if !ok {
+		return nil, nil
+	}
+
+	var videos []models.Video
+	for _, id := range s.sortedVideoIDs() {
+		if _, ok := s.videoTags[id][tagID]; ok {
+			videos = append(videos, s.videos[id])
+		}
+	}
+
+	return videos, nil
+}
+
+// FindVideos возвращает видео по запросу тегов, начиная с самых новых
+func (s *MemoryStore) FindVideos(query tagquery.Expr, opts FindOptions) ([]models.Video, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := s.findMatches(s.resolveQuery(query), opts)
+	total := len(matched)
+	if opts.Offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[opts.Offset:]
+	if opts.Limit > 0 && len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+	return matched, total, nil
+}
+
+// SampleVideos возвращает до opts.Limit случайных видео из тех, что нашел
+// бы FindVideos, и общее число совпадений
+func (s *MemoryStore) SampleVideos(query tagquery.Expr, opts FindOptions) ([]models.Video, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := s.findMatches(s.resolveQuery(query), opts)
+	total := len(matched)
+	rand.Shuffle(total, func(i, j int) {
+		matched[i], matched[j] = matched[j], matched[i]
+	})
+	if len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+	return matched, total, nil
+}
+
+// findMatches возвращает все видео под запрос FindVideos в порядке выдачи.
+// Вызывается под блокировкой
+func (s *MemoryStore) findMatches(query tagquery.Expr, opts FindOptions) []models.Video {
+	caption := strings.ToLower(opts.Caption)
+	var matched, seen []models.Video
+	ids := s.sortedVideoIDs()
+	for i := len(ids) - 1; i >= 0; i-- {
+		id := ids[i]
+		_, sent := s.sent[opts.ChatID][id]
+		if opts.ExcludeSent && sent {
+			continue
+		}
+
+		v := s.videos[id]
+		tags := s.videoTagNames(id)
+		ok := query == nil && caption == ""
+		if query != nil && query.Match(tags) {
+			ok = true
+		}
+		if caption != "" && strings.Contains(strings.ToLower(v.Caption), caption) {
+			ok = true
+		}
+		if !ok {
+			continue
+		}
+
+		v.Tags = tags
+		if opts.UnseenFirst && sent {
+			seen = append(seen, v)
+		} else {
+			matched = append(matched, v)
+		}
+	}
+	return append(matched, seen...)
+}
+
+// AddTagsToVideo добавляет теги к видео
+func (s *MemoryStore) AddTagsToVideo(videoID int64, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.videos[videoID]; !ok {
+		return fmt.Errorf("ошибка связывания видео и тега: видео с ID %d не найдено", videoID)
+	}
+
+	s.addTags(videoID, tags)
+	return nil
+}
+
+// addTags создает недостающие теги и связывает их с видео.
+// Вызывается под блокировкой на запись
+func (s *MemoryStore) addTags(videoID int64, tags []string) {
+	for _, tagName := range tags {
+		tagName = utilities.NormalizeTag(tagName)
+		if tagName == "" {
+			continue
+		}
+
+		tagID, ok := s.lookupTag(tagName)
+		if !ok {
+			s.lastTagID++
+			tagID = s.lastTagID
+			s.tagIDs[tagName] = tagID
+			s.tagNames[tagID] = tagName
+		}
+
+		if s.videoTags[videoID] == nil {
+			s.videoTags[videoID] = make(map[int64]struct{})
+		}
+		s.videoTags[videoID][tagID] = struct{}{}
+	}
+}
+
+// GetVideoTags возвращает все теги для видео
+func (s *MemoryStore) GetVideoTags(videoID int64) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.videoTagNames(videoID), nil
+}
+
+// IsVideoSent проверяет, отправлялось ли видео в указанный чат
+func (s *MemoryStore) IsVideoSent(chatID, videoID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.sent[chatID][videoID]
+	return ok
+}
+
+// MarkVideoSent отмечает видео как отправленное в чат. Повторная отправка
+// обновляет время просмотра
+func (s *MemoryStore) MarkVideoSent(chatID, videoID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.videos[videoID]; !ok {
+		return fmt.Errorf("видео с ID %d не найдено", videoID)
+	}
+
+	if s.sent[chatID] == nil {
+		s.sent[chatID] = make(map[int64]time.Time)
+	}
+	s.sent[chatID][videoID] = time.Now()
+
+	return nil
+}
+
+// ResetSentVideos очищает историю отправок чата
+func (s *MemoryStore) ResetSentVideos(chatID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.sent[chatID])
+	delete(s.sent, chatID)
+	return n, nil
+}
+
+// GetReplayVideos возвращает отправленные в чат видео по возрастанию
+// времени последнего просмотра
+func (s *MemoryStore) GetReplayVideos(chatID int64, seenBefore time.Time, limit int) ([]models.Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sent := s.sent[chatID]
+	var ids []int64
+	for id, at := range sent {
+		if seenBefore.IsZero() || at.Before(seenBefore) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if !sent[ids[i]].Equal(sent[ids[j]]) {
+			return sent[ids[i]].Before(sent[ids[j]])
+		}
+		return ids[i] < ids[j]
+	})
+
+	var videos []models.Video
+	for i := 0; i < len(ids) && i < limit; i++ {
+		v := s.videos[ids[i]]
+		v.Tags = s.videoTagNames(v.ID)
+		videos = append(videos, v)
+	}
+	return videos, nil
+}
+
+// GetPopularTags возвращает самые популярные теги
+func (s *MemoryStore) GetPopularTags(limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[int64]int)
+	for _, tagIDs := range s.videoTags {
+		for tagID := range tagIDs {
+			counts[tagID]++
+		}
+	}
+
+	tags := make([]string, 0, len(counts))
+	for tagID := range counts {
+		tags = append(tags, s.tagNames[tagID])
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		ci, cj := counts[s.tagIDs[tags[i]]], counts[s.tagIDs[tags[j]]]
+		if ci != cj {
+			return ci > cj
+		}
+		return tags[i] < tags[j]
+	})
+
+	if limit >= 0 && len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// ListTags возвращает страницу тегов с числом видео, начиная с самых
+// используемых
+func (s *MemoryStore) ListTags(limit, offset int) ([]models.TagCount, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[int64]int)
+	for _, tagIDs := range s.videoTags {
+		for tagID := range tagIDs {
+			counts[tagID]++
+		}
+	}
+
+	tags := make([]models.TagCount, 0, len(s.tagNames))
+	for tagID, name := range s.tagNames {
+		tags = append(tags, models.TagCount{Tag: name, Count: counts[tagID]})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	total := len(tags)
+	if offset >= total {
+		return nil, total, nil
+	}
+	tags = tags[offset:]
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, total, nil
+}
+
+// RenameTag переименовывает тег, старое имя остается его псевдонимом
+func (s *MemoryStore) RenameTag(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newName = utilities.NormalizeTag(newName)
+	tagID, ok := s.lookupTag(oldName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, utilities.NormalizeTag(oldName))
+	}
+	oldName = s.tagNames[tagID]
+	if oldName == newName {
+		return nil
+	}
+	if _, ok := s.tagIDs[newName]; ok {
+		return fmt.Errorf("%w: %s", ErrTagExists, newName)
+	}
+	if aliasOf, ok := s.aliases[newName]; ok && aliasOf != tagID {
+		return fmt.Errorf("%w: %s", ErrTagExists, newName)
+	}
+
+	delete(s.aliases, newName)
+	delete(s.tagIDs, oldName)
+	s.tagIDs[newName] = tagID
+	s.tagNames[tagID] = newName
+	s.aliases[oldName] = tagID
+	return nil
+}
+
+// MergeTags переносит видео тегов from на тег into и удаляет теги from;
+// их имена и псевдонимы становятся псевдонимами into
+func (s *MemoryStore) MergeTags(from []string, into string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	into = utilities.NormalizeTag(into)
+	intoID, ok := s.lookupTag(into)
+	var fromIDs []int64
+	seen := make(map[int64]bool)
+	for _, name := range from {
+		tagID, ok := s.lookupTag(name)
+		if !ok {
+			return 0, fmt.Errorf("ошибка объединения тегов: %w: %s", ErrTagNotFound, utilities.NormalizeTag(name))
+		}
+		// Имя и псевдоним одного тега дают его один раз
+		if tagID != intoID && !seen[tagID] {
+			seen[tagID] = true
+			fromIDs = append(fromIDs, tagID)
+		}
+	}
+
+	if !ok {
+		s.lastTagID++
+		intoID = s.lastTagID
+		s.tagIDs[into] = intoID
+		s.tagNames[intoID] = into
+	}
+
+	moved := 0
+	for _, tagIDs := range s.videoTags {
+		for _, fromID := range fromIDs {
+			if _, ok := tagIDs[fromID]; !ok {
+				continue
+			}
+			if _, ok := tagIDs[intoID]; !ok {
+				tagIDs[intoID] = struct{}{}
+				moved++
+			}
+		}
+	}
+	for _, fromID := range fromIDs {
+		for alias, tagID := range s.aliases {
+			if tagID == fromID {
+				s.aliases[alias] = intoID
+			}
+		}
+		name := s.tagNames[fromID]
+		s.deleteTag(fromID)
+		s.aliases[name] = intoID
+	}
+	return moved, nil
+}
+
+// DeleteTag удаляет тег и возвращает число видео, с которых он снят
+func (s *MemoryStore) DeleteTag(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagID, ok := s.lookupTag(name)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrTagNotFound, utilities.NormalizeTag(name))
+	}
+	return s.deleteTag(tagID), nil
+}
+
+// deleteTag удаляет тег со связями и псевдонимами и возвращает число видео, с
+// которых он снят. Вызывается под блокировкой на запись
+func (s *MemoryStore) deleteTag(tagID int64) int {
+	videos := 0
+	for _, tagIDs := range s.videoTags {
+		if _, ok := tagIDs[tagID]; ok {
+			delete(tagIDs, tagID)
+			videos++
+		}
+	}
+	for alias, aliasOf := range s.aliases {
+		if aliasOf == tagID {
+			delete(s.aliases, alias)
+		}
+	}
+	delete(s.tagIDs, s.tagNames[tagID])
+	delete(s.tagNames, tagID)
+	return videos
+}
+
+// RemoveTagsFromVideo снимает теги с видео и возвращает число снятых
+func (s *MemoryStore) RemoveTagsFromVideo(videoID int64, tags []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, name := range tags {
+		tagID, ok := s.lookupTag(name)
+		if !ok {
+			continue
+		}
+		if _, ok := s.videoTags[videoID][tagID]; ok {
+			delete(s.videoTags[videoID], tagID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// VideoExists проверяет существование видео по ID
+func (s *MemoryStore) VideoExists(id int64) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.videos[id]
+	return ok, nil
+}
+
+// GetRandomUnsentVideo возвращает случайные видео, которые еще не были отправлены в указанный чат
+func (s *MemoryStore) GetRandomUnsentVideo(chatID int64, limit int) ([]models.Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var unsent []int64
+	for id := range s.videos {
+		if _, ok := s.sent[chatID][id]; !ok {
+			unsent = append(unsent, id)
+		}
+	}
+	rand.Shuffle(len(unsent), func(i, j int) {
+		unsent[i], unsent[j] = unsent[j], unsent[i]
+	})
+
+	var videos []models.Video
+	for i := 0; i < len(unsent) && i < limit; i++ {
+		v := s.videos[unsent[i]]
+		v.Tags = s.videoTagNames(v.ID)
+		videos = append(videos, v)
+	}
+
+	return videos, nil
+}
+
+// GetAllVideos возвращает все видео, начиная с самых новых
+func (s *MemoryStore) GetAllVideos() ([]models.Video, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.sortedVideoIDs()
+	videos := make([]models.Video, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		videos = append(videos, s.videos[ids[i]])
+	}
+
+	return videos, nil
+}
+
+// DeleteVideo удаляет видео вместе с его тегами и историей отправок
+func (s *MemoryStore) DeleteVideo(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.videos[id]
+	if !ok {
+		return nil
+	}
+
+	delete(s.videos, id)
+	delete(s.fileIDs, v.FileID)
+	delete(s.uniqueIDs, v.FileUniqueID)
+	delete(s.videoTags, id)
+	for _, videos := range s.sent {
+		delete(videos, id)
+	}
+
+	return nil
+}
+
+// GetUpdateOffset возвращает последний полностью обработанный update_id
+func (s *MemoryStore) GetUpdateOffset(botID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.offsets[botID], nil
+}
+
+// SaveUpdateOffset сохраняет offset и забывает апдейты до него
+func (s *MemoryStore) SaveUpdateOffset(botID int64, lastUpdateID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offsets[botID] = lastUpdateID
+	for id := range s.processed[botID] {
+		if id <= lastUpdateID {
+			delete(s.processed[botID], id)
+		}
+	}
+
+	return nil
+}
+
+// IsUpdateProcessed проверяет, был ли апдейт уже обработан
+func (s *MemoryStore) IsUpdateProcessed(botID int64, updateID int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.processed[botID][updateID]
+	return ok, nil
+}
+
+// MarkUpdateProcessed отмечает апдейт как обработанный
+func (s *MemoryStore) MarkUpdateProcessed(botID int64, updateID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.processed[botID] == nil {
+		s.processed[botID] = make(map[int]struct{})
+	}
+	s.processed[botID][updateID] = struct{}{}
+
+	return nil
+}
+
+// GetUserRole возвращает роль пользователя; без записи это viewer
+func (s *MemoryStore) GetUserRole(userID int64) (models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if u, ok := s.users[userID]; ok {
+		return u.Role, nil
+	}
+	return models.RoleViewer, nil
+}
+
+// SetUserRole выдает пользователю роль
+func (s *MemoryStore) SetUserRole(userID int64, role models.Role, grantedBy int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID] = models.UserRole{UserID: userID, Role: role, GrantedBy: grantedBy}
+	return nil
+}
+
+// DeleteUserRole убирает запись о роли
+func (s *MemoryStore) DeleteUserRole(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, userID)
+	return nil
+}
+
+// ListUserRoles возвращает всех пользователей с выданными ролями
+func (s *MemoryStore) ListUserRoles() ([]models.UserRole, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.UserRole, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if li, lj := users[i].Role.Level(), users[j].Role.Level(); li != lj {
+			return li > lj
+		}
+		return users[i].UserID < users[j].UserID
+	})
+	return users, nil
+}
+
+// RecordDelivery пишет событие отправки видео в чат
+func (s *MemoryStore) RecordDelivery(chatID, videoID int64, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = append(s.deliveries, delivery{chatID: chatID, videoID: videoID, source: source, at: time.Now()})
+	return nil
+}
+
+// RecordTagRequest пишет событие поиска по тегу
+func (s *MemoryStore) RecordTagRequest(chatID int64, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tagRequests = append(s.tagRequests, tagRequest{chatID: chatID, tag: tag, at: time.Now()})
+	return nil
+}
+
+// GetStats собирает статистику за период начиная с since
+func (s *MemoryStore) GetStats(since time.Time, limit int) (models.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := models.Stats{
+		Since:       since,
+		TotalVideos: len(s.videos),
+		TotalTags:   len(s.tagIDs),
+		TotalChats:  len(s.sent),
+	}
+
+	days := make(map[string]int)
+	videos := make(map[int64]int)
+	tags := make(map[string]int)
+	chats := make(map[int64]int)
+	for _, d := range s.deliveries {
+		if d.at.Before(since) {
+			continue
+		}
+		stats.Sent++
+		days[d.at.UTC().Format("2006-01-02")]++
+		videos[d.videoID]++
+		chats[d.chatID]++
+		for tagID := range s.videoTags[d.videoID] {
+			tags[s.tagNames[tagID]]++
+		}
+	}
+	stats.ActiveChats = len(chats)
+
+	requests := make(map[string]int)
+	for _, r := range s.tagRequests {
+		if !r.at.Before(since) {
+			requests[r.tag]++
+		}
+	}
+
+	for day, count := range days {
+		stats.PerDay = append(stats.PerDay, models.DayCount{Day: day, Count: count})
+	}
+	sort.Slice(stats.PerDay, func(i, j int) bool { return stats.PerDay[i].Day < stats.PerDay[j].Day })
+
+	for id, count := range videos {
+		stats.TopVideos = append(stats.TopVideos, models.VideoCount{VideoID: id, Caption: s.videos[id].Caption, Count: count})
+	}
+	sort.Slice(stats.TopVideos, func(i, j int) bool {
+		a, b := stats.TopVideos[i], stats.TopVideos[j]
+		return a.Count > b.Count || a.Count == b.Count && a.VideoID < b.VideoID
+	})
+
+	stats.TopTags = topTagCounts(tags)
+	stats.TopRequests = topTagCounts(requests)
+
+	for id, count := range chats {
+		stats.TopChats = append(stats.TopChats, models.ChatCount{ChatID: id, Sent: count, Seen: len(s.sent[id])})
+	}
+	sort.Slice(stats.TopChats, func(i, j int) bool {
+		a, b := stats.TopChats[i], stats.TopChats[j]
+		return a.Sent > b.Sent || a.Sent == b.Sent && a.ChatID < b.ChatID
+	})
+
+	if len(stats.TopVideos) > limit {
+		stats.TopVideos = stats.TopVideos[:limit]
+	}
+	if len(stats.TopTags) > limit {
+		stats.TopTags = stats.TopTags[:limit]
+	}
+	if len(stats.TopRequests) > limit {
+		stats.TopRequests = stats.TopRequests[:limit]
+	}
+	if len(stats.TopChats) > limit {
+		stats.TopChats = stats.TopChats[:limit]
+	}
+
+	return stats, nil
+}
+
+func topTagCounts(counts map[string]int) []models.TagCount {
+	top := make([]models.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		top = append(top, models.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].Count > top[j].Count || top[i].Count == top[j].Count && top[i].Tag < top[j].Tag
+	})
+	return top
+}
+
+// GetChatState возвращает состояние диалога в чате
+func (s *MemoryStore) GetChatState(chatID int64) (models.ChatState, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.states[chatID]
+	if !ok {
+		return models.ChatState{}, false, nil
+	}
+
+	data := make(map[string]string, len(state.Data))
+	for k, v := range state.Data {
+		data[k] = v
+	}
+	state.Data = data
+	return state, true, nil
+}
+
+// SaveChatState сохраняет состояние диалога, заменяя предыдущее
+func (s *MemoryStore) SaveChatState(state models.ChatState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make(map[string]string, len(state.Data))
+	for k, v := range state.Data {
+		data[k] = v
+	}
+	state.Data = data
+	s.states[state.ChatID] = state
+	return nil
+}
+
+// DeleteChatState завершает диалог в чате
+func (s *MemoryStore) DeleteChatState(chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, chatID)
+	return nil
+}
+
+// Close ничего не делает: хранилищу в памяти нечего освобождать
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// videoTagNames возвращает имена тегов видео; вызывается под блокировкой
+func (s *MemoryStore) videoTagNames(videoID int64) []string {
+	var tags []string
+	for tagID := range s.videoTags[videoID] {
+		tags = append(tags, s.tagNames[tagID])
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// sortedVideoIDs возвращает ID видео по возрастанию; вызывается под блокировкой
+func (s *MemoryStore) sortedVideoIDs() []int64 {
+	ids := make([]int64, 0, len(s.videos))
+	for id := range s.videos {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// AddTagAlias делает alias псевдонимом тега tag
+func (s *MemoryStore) AddTagAlias(alias, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alias = utilities.NormalizeTag(alias)
+	tagID, ok := s.lookupTag(tag)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, utilities.NormalizeTag(tag))
+	}
+	if _, ok := s.tagIDs[alias]; ok {
+		return fmt.Errorf("%w: %s", ErrTagExists, alias)
+	}
+
+	s.aliases[alias] = tagID
+	return nil
+}
+
+// DeleteTagAlias удаляет псевдоним
+func (s *MemoryStore) DeleteTagAlias(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alias = utilities.NormalizeTag(alias)
+	if _, ok := s.aliases[alias]; !ok {
+		return fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+	}
+	delete(s.aliases, alias)
+	return nil
+}
+
+// ListTagAliases возвращает все псевдонимы, сгруппированные по тегам
+func (s *MemoryStore) ListTagAliases() ([]models.TagAlias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := make([]models.TagAlias, 0, len(s.aliases))
+	for alias, tagID := range s.aliases {
+		aliases = append(aliases, models.TagAlias{Alias: alias, Tag: s.tagNames[tagID]})
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if aliases[i].Tag != aliases[j].Tag {
+			return aliases[i].Tag < aliases[j].Tag
+		}
+		return aliases[i].Alias < aliases[j].Alias
+	})
+	return aliases, nil
+}
+
+// LookupTag возвращает каноничное имя тега по имени или псевдониму
+func (s *MemoryStore) LookupTag(name string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tagID, ok := s.lookupTag(name)
+	return s.tagNames[tagID], ok, nil
+}
+
+// ResolveTag возвращает каноничное имя тега по имени, псевдониму или
+// другой записи того же слова
+func (s *MemoryStore) ResolveTag(name string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tagID, ok := s.resolveTag(name)
+	return s.tagNames[tagID], ok, nil
+}
+
+// resolveQuery подставляет в запрос каноничные имена тегов. Вызывается под
+// блокировкой
+func (s *MemoryStore) resolveQuery(query tagquery.Expr) tagquery.Expr {
+	if query == nil {
+		return nil
+	}
+	return tagquery.MapTags(query, func(tag string) string {
+		if tagID, ok := s.resolveTag(tag); ok {
+			return s.tagNames[tagID]
+		}
+		return tag
+	})
+}
+
+// lookupTag ищет тег как VideoRepository: по имени, затем по псевдониму.
+// Вызывается под блокировкой
+func (s *MemoryStore) lookupTag(name string) (int64, bool) {
+	name = utilities.NormalizeTag(name)
+	if tagID, ok := s.tagIDs[name]; ok {
+		return tagID, true
+	}
+	tagID, ok := s.aliases[name]
+	return tagID, ok
+}
+
+// resolveTag ищет тег для поиска как VideoRepository: как lookupTag, затем
+// по транслитерации. Вызывается под блокировкой
+func (s *MemoryStore) resolveTag(name string) (int64, bool) {
+	if tagID, ok := s.lookupTag(name); ok {
+		return tagID, true
+	}
+	name = utilities.NormalizeTag(name)
+	if name == "" {
+		return 0, false
+	}
+
+	// При нескольких совпадениях выигрывает самый старый тег
+	key := utilities.TranslitKey(name)
+	var found int64
+	for spelling, tagID := range s.tagIDs {
+		if (found == 0 || tagID < found) && utilities.TranslitKey(spelling) == key {
+			found = tagID
+		}
+	}
+	for spelling, tagID := range s.aliases {
+		if (found == 0 || tagID < found) && utilities.TranslitKey(spelling) == key {
+			found = tagID
+		}
+	}
+	return found, found != 0
+}
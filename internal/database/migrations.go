@@ -1,8 +1,13 @@
 package database
 
+import "database/sql"
+
 type Migration struct {
 	Name     string
 	Commands []string
+	// Run выполняется после Commands в той же транзакции — для данных,
+	// которые нельзя посчитать в SQL
+	Run func(tx *sql.Tx) error
 }
 
 var migrations = []Migration{
@@ -45,4 +50,330 @@ var migrations = []Migration{
 			) ENGINE=InnoDB`,
 		},
 	},
+	{
+		Name: "02_update_offsets",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS update_offsets (
+				bot_id BIGINT NOT NULL PRIMARY KEY,
+				last_update_id BIGINT NOT NULL,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			) ENGINE=InnoDB`,
+
+			`CREATE TABLE IF NOT EXISTS processed_updates (
+				bot_id BIGINT NOT NULL,
+				update_id BIGINT NOT NULL,
+				processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (bot_id, update_id)
+			) ENGINE=InnoDB`,
+		},
+	},
+	{
+		Name: "03_users_roles",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS roles (
+				name VARCHAR(16) NOT NULL PRIMARY KEY,
+				level INT NOT NULL
+			) ENGINE=InnoDB`,
+
+			`INSERT IGNORE INTO roles (name, level) VALUES
+				('banned', 0), ('viewer', 1), ('uploader', 2),
+				('moderator', 3), ('admin', 4), ('owner', 5)`,
+
+			`CREATE TABLE IF NOT EXISTS users (
+				user_id BIGINT NOT NULL PRIMARY KEY,
+				role VARCHAR(16) NOT NULL,
+				granted_by BIGINT,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				CONSTRAINT fk_users_role
+					FOREIGN KEY (role) REFERENCES roles(name)
+			) ENGINE=InnoDB`,
+		},
+	},
+	{
+		Name: "04_stats_events",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS video_deliveries (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				chat_id BIGINT NOT NULL,
+				video_id INT NOT NULL,
+				source VARCHAR(16) NOT NULL,
+				delivered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_video_deliveries_at (delivered_at),
+				INDEX idx_video_deliveries_chat (chat_id, delivered_at)
+			) ENGINE=InnoDB`,
+
+			`CREATE TABLE IF NOT EXISTS tag_requests (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				chat_id BIGINT NOT NULL,
+				tag VARCHAR(50) NOT NULL,
+				requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_tag_requests_at (requested_at)
+			) ENGINE=InnoDB`,
+		},
+	},
+	{
+		Name: "05_chat_states",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS chat_states (
+				chat_id BIGINT NOT NULL PRIMARY KEY,
+				user_id BIGINT NOT NULL,
+				state VARCHAR(32) NOT NULL,
+				data TEXT,
+				expires_at TIMESTAMP NOT NULL
+			) ENGINE=InnoDB`,
+		},
+	},
+	{
+		Name: "06_video_metadata",
+		Commands: []string{
+			`ALTER TABLE videos
+				ADD COLUMN duration INT NOT NULL DEFAULT 0,
+				ADD COLUMN width INT NOT NULL DEFAULT 0,
+				ADD COLUMN height INT NOT NULL DEFAULT 0,
+				ADD COLUMN mime_type VARCHAR(64) NOT NULL DEFAULT '',
+				ADD COLUMN file_size BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN thumb_file_id VARCHAR(255),
+				ADD COLUMN uploader_id BIGINT`,
+		},
+	},
+	{
+		Name: "07_file_unique_id",
+		Commands: []string{
+			`ALTER TABLE videos
+				ADD COLUMN file_unique_id VARCHAR(64) NULL,
+				ADD UNIQUE INDEX idx_videos_file_unique_id (file_unique_id)`,
+		},
+	},
+	{
+		Name: "08_media",
+		Commands: []string{
+			`RENAME TABLE videos TO media`,
+
+			`ALTER TABLE media ADD COLUMN media_type VARCHAR(16) NOT NULL DEFAULT 'video'`,
+		},
+	},
+	{
+		Name: "09_albums",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS albums (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				caption TEXT,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			) ENGINE=InnoDB`,
+
+			`ALTER TABLE media
+				ADD COLUMN album_id INT NULL,
+				ADD CONSTRAINT fk_media_album
+					FOREIGN KEY (album_id) REFERENCES albums(id)
+					ON DELETE SET NULL`,
+		},
+	},
+	{
+		Name: "10_sent_history",
+		Commands: []string{
+			// Повторы выбирают давно просмотренные видео чата
+			`ALTER TABLE sent_videos ADD INDEX idx_sent_videos_chat_time (chat_id, sent_at)`,
+		},
+	},
+	{
+		Name: "11_tag_aliases",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS tag_aliases (
+				alias VARCHAR(50) NOT NULL PRIMARY KEY,
+				tag_id INT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT fk_tag_aliases_tag
+					FOREIGN KEY (tag_id) REFERENCES tags(id)
+					ON DELETE CASCADE
+			) ENGINE=InnoDB`,
+		},
+	},
+	{
+		Name: "12_tag_translit_keys",
+		Commands: []string{
+			// Ключ utilities.TranslitKey, по которому поиск находит тег в другом алфавите
+			`ALTER TABLE tags
+				ADD COLUMN translit_key VARCHAR(150) NOT NULL DEFAULT '',
+				ADD INDEX idx_tags_translit (translit_key)`,
+			`ALTER TABLE tag_aliases
+				ADD COLUMN translit_key VARCHAR(150) NOT NULL DEFAULT '',
+				ADD INDEX idx_tag_aliases_translit (translit_key)`,
+		},
+		Run: fillTranslitKeys,
+	},
+}
+
+var sqliteMigrations = []Migration{
+	{
+		Name: "01_create_tables",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS videos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				file_id TEXT NOT NULL UNIQUE,
+				caption TEXT,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+
+			`CREATE TABLE IF NOT EXISTS tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+
+			`CREATE TABLE IF NOT EXISTS video_tags (
+				video_id INTEGER NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+				tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+				PRIMARY KEY (video_id, tag_id)
+			)`,
+
+			`CREATE TABLE IF NOT EXISTS sent_videos (
+				chat_id INTEGER NOT NULL,
+				video_id INTEGER NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+				sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (chat_id, video_id)
+			)`,
+		},
+	},
+	{
+		Name: "02_update_offsets",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS update_offsets (
+				bot_id INTEGER NOT NULL PRIMARY KEY,
+				last_update_id INTEGER NOT NULL,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+
+			`CREATE TABLE IF NOT EXISTS processed_updates (
+				bot_id INTEGER NOT NULL,
+				update_id INTEGER NOT NULL,
+				processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (bot_id, update_id)
+			)`,
+		},
+	},
+	{
+		Name: "03_users_roles",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS roles (
+				name TEXT NOT NULL PRIMARY KEY,
+				level INTEGER NOT NULL
+			)`,
+
+			`INSERT OR IGNORE INTO roles (name, level) VALUES
+				('banned', 0), ('viewer', 1), ('uploader', 2),
+				('moderator', 3), ('admin', 4), ('owner', 5)`,
+
+			`CREATE TABLE IF NOT EXISTS users (
+				user_id INTEGER NOT NULL PRIMARY KEY,
+				role TEXT NOT NULL REFERENCES roles(name),
+				granted_by INTEGER,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		Name: "04_stats_events",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS video_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				chat_id INTEGER NOT NULL,
+				video_id INTEGER NOT NULL,
+				source TEXT NOT NULL,
+				delivered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+
+			`CREATE INDEX IF NOT EXISTS idx_video_deliveries_at ON video_deliveries (delivered_at)`,
+
+			`CREATE INDEX IF NOT EXISTS idx_video_deliveries_chat ON video_deliveries (chat_id, delivered_at)`,
+
+			`CREATE TABLE IF NOT EXISTS tag_requests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				chat_id INTEGER NOT NULL,
+				tag TEXT NOT NULL,
+				requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+
+			`CREATE INDEX IF NOT EXISTS idx_tag_requests_at ON tag_requests (requested_at)`,
+		},
+	},
+	{
+		Name: "05_chat_states",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS chat_states (
+				chat_id INTEGER NOT NULL PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				state TEXT NOT NULL,
+				data TEXT,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		Name: "06_video_metadata",
+		Commands: []string{
+			`ALTER TABLE videos ADD COLUMN duration INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE videos ADD COLUMN width INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE videos ADD COLUMN height INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE videos ADD COLUMN mime_type TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE videos ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE videos ADD COLUMN thumb_file_id TEXT`,
+			`ALTER TABLE videos ADD COLUMN uploader_id INTEGER`,
+		},
+	},
+	{
+		Name: "07_file_unique_id",
+		Commands: []string{
+			`ALTER TABLE videos ADD COLUMN file_unique_id TEXT`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_videos_file_unique_id ON videos (file_unique_id)`,
+		},
+	},
+	{
+		Name: "08_media",
+		Commands: []string{
+			// Ссылки из video_tags и sent_videos SQLite переименует сама
+			`ALTER TABLE videos RENAME TO media`,
+			`ALTER TABLE media ADD COLUMN media_type TEXT NOT NULL DEFAULT 'video'`,
+		},
+	},
+	{
+		Name: "09_albums",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS albums (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				caption TEXT,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+
+			`ALTER TABLE media ADD COLUMN album_id INTEGER REFERENCES albums(id) ON DELETE SET NULL`,
+
+			`CREATE INDEX IF NOT EXISTS idx_media_album ON media (album_id)`,
+		},
+	},
+	{
+		Name: "10_sent_history",
+		Commands: []string{
+			`CREATE INDEX IF NOT EXISTS idx_sent_videos_chat_time ON sent_videos (chat_id, sent_at)`,
+		},
+	},
+	{
+		Name: "11_tag_aliases",
+		Commands: []string{
+			`CREATE TABLE IF NOT EXISTS tag_aliases (
+				alias TEXT NOT NULL PRIMARY KEY,
+				tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_tag_aliases_tag ON tag_aliases (tag_id)`,
+		},
+	},
+	{
+		Name: "12_tag_translit_keys",
+		Commands: []string{
+			`ALTER TABLE tags ADD COLUMN translit_key TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tags_translit ON tags (translit_key)`,
+			`ALTER TABLE tag_aliases ADD COLUMN translit_key TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tag_aliases_translit ON tag_aliases (translit_key)`,
+		},
+		Run: fillTranslitKeys,
+	},
 }
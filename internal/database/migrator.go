@@ -9,11 +9,12 @@ import (
 )
 
 type Migrator struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
-func NewMigrator(db *sql.DB) *Migrator {
-	return &Migrator{db: db}
+func NewMigrator(db *sql.DB, dialect Dialect) *Migrator {
+	return &Migrator{db: db, dialect: dialect}
 }
 
 func (m *Migrator) Run() error {
@@ -28,7 +29,7 @@ func (m *Migrator) Run() error {
 	}
 
 	// Применяем миграции по очереди
-	for _, migration := range migrations {
+	for _, migration := range m.dialect.Migrations {
 		if _, ok := applied[migration.Name]; !ok {
 			if err := m.applyMigration(migration); err != nil {
 				return fmt.Errorf("migration %s failed: %v", migration.Name, err)
@@ -57,6 +58,11 @@ func (m *Migrator) applyMigration(migration Migration) error {
 			return fmt.Errorf("failed to execute command:\n%s\nError: %v", cmd, err)
 		}
 	}
+	if migration.Run != nil {
+		if err := migration.Run(tx); err != nil {
+			return err
+		}
+	}
 
 	// Фиксируем миграцию
 	if _, err := tx.ExecContext(
@@ -71,12 +77,7 @@ func (m *Migrator) applyMigration(migration Migration) error {
 }
 
 func (m *Migrator) createMigrationsTable() error {
-	_, err := m.db.Exec(`
-		CREATE TABLE IF NOT EXISTS migrations (
-			id INT AUTO_INCREMENT PRIMARY KEY,
-			name VARCHAR(255) NOT NULL UNIQUE,
-			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	_, err := m.db.Exec(m.dialect.MigrationsTable)
 	return err
 }
 
@@ -0,0 +1,352 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"tg-video-bot/internal/models"
+)
+
+// newTestSQLite создает репозиторий SQLite во временном каталоге теста
+func newTestSQLite(tb testing.TB) *VideoRepository {
+	tb.Helper()
+	db, err := InitSQLite(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	repo := NewSQLiteRepository(db)
+	tb.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// TestGetRandomUnsentVideoUniform проверяет, что выбор не зависит от того,
+// как в таблице расположены отправленные видео и пропуски в ID: видео сразу
+// за длинной серией отправленных выпадает не чаще остальных
+func TestGetRandomUnsentVideoUniform(t *testing.T) {
+	const chatID = 1
+	repo := newTestSQLite(t)
+	if err := populateVideos(repo.db, repo.dialect, 1, 120, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Отправлены 1..100, кроме каждого 20-го; из 101..120 удален каждый второй
+	unsent := make(map[int64]int)
+	for id := int64(1); id <= 120; id++ {
+		switch {
+		case id <= 100 && id%20 != 0:
+			if err := repo.MarkVideoSent(chatID, id); err != nil {
+				t.Fatal(err)
+			}
+		case id > 100 && id%2 == 0:
+			if err := repo.DeleteVideo(id); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			unsent[id] = 0
+		}
+	}
+
+	const draws = 1500
+	for i := 0; i < draws; i++ {
+		videos, err := repo.GetRandomUnsentVideo(chatID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 1 {
+			t.Fatalf("got %d videos, want 1", len(videos))
+		}
+		if _, ok := unsent[videos[0].ID]; !ok {
+			t.Fatalf("got video %d, which is sent or deleted", videos[0].ID)
+		}
+		unsent[videos[0].ID]++
+	}
+
+	// Ожидается по 100 выборов на видео, стандартное отклонение около 10.
+	// При выборе первого неотправленного после случайного ID видео 20
+	// выпадало бы примерно 250 раз
+	want := draws / len(unsent)
+	for id, n := range unsent {
+		if n < want/2 || n > want*2 {
+			t.Errorf("video %d picked %d times, want about %d", id, n, want)
+		}
+	}
+
+	videos, err := repo.GetRandomUnsentVideo(chatID, len(unsent)+5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int64]bool)
+	for _, v := range videos {
+		if seen[v.ID] {
+			t.Fatalf("video %d returned twice", v.ID)
+		}
+		seen[v.ID] = true
+	}
+	if len(videos) != len(unsent) {
+		t.Fatalf("got %d videos, want all %d unsent", len(videos), len(unsent))
+	}
+}
+
+// Чаты с историей отправок в бенчмарках: в uniformChat каждое видео
+// отправлено независимо, в sampledChat история набрана самим
+// GetRandomUnsentVideo, как это происходит в работе бота
+const (
+	uniformChat = 1
+	sampledChat = 2
+)
+
+// BenchmarkGetRandomUnsentVideo сравнивает прежний ORDER BY RAND() с
+// выборкой по первичному ключу на синтетических библиотеках разного
+// размера и с разной долей уже отправленных видео. По умолчанию
+// используется SQLite; чтобы замерить MySQL, задайте BENCH_MYSQL=1 и
+// DB_* пустой базы, как для запуска бота:
+//
+//	go test -run '^$' -bench RandomUnsent ./internal/database
+func BenchmarkGetRandomUnsentVideo(b *testing.B) {
+	sizes := []int{10000, 100000}
+	if testing.Short() {
+		sizes = sizes[:1]
+	}
+
+	for _, size := range sizes {
+		repo := newBenchRepo(b)
+		if err := populateVideos(repo.db, repo.dialect, 1, size, 500, 3); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, seen := range []float64{0, 0.5, 0.9, 0.99} {
+			if err := sendUniform(repo, uniformChat, size, seen); err != nil {
+				b.Fatal(err)
+			}
+			if err := sendSampled(repo, sampledChat, size, seen); err != nil {
+				b.Fatal(err)
+			}
+
+			for _, chat := range []struct {
+				name string
+				id   int64
+			}{
+				{"uniform", uniformChat},
+				{"sampled", sampledChat},
+			} {
+				prefix := fmt.Sprintf("%s/videos=%d/seen=%.2f/%s", repo.dialect.Name, size, seen, chat.name)
+				b.Run(prefix+"/order-by-rand", func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						if _, err := legacyRandomUnsent(repo, chat.id, 5); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+				b.Run(prefix+"/pk-sample", func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						if _, err := repo.GetRandomUnsentVideo(chat.id, 5); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+		closeBenchRepo(b, repo)
+	}
+}
+
+// newBenchRepo возвращает пустой репозиторий: SQLite во временном каталоге
+// или MySQL из DB_*, если задан BENCH_MYSQL. Базу MySQL бенчмарк очищает
+// после себя, поэтому она должна быть пустой
+func newBenchRepo(b *testing.B) *VideoRepository {
+	b.Helper()
+	if os.Getenv("BENCH_MYSQL") == "" {
+		return newTestSQLite(b)
+	}
+
+	db, err := InitDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	var videos int
+	if err := db.QueryRow("SELECT COUNT(*) FROM media").Scan(&videos); err != nil {
+		b.Fatal(err)
+	}
+	if videos > 0 {
+		db.Close()
+		b.Fatalf("BENCH_MYSQL: в базе %s уже есть видео, нужна пустая база", os.Getenv("DB_NAME"))
+	}
+	return NewVideoRepository(db)
+}
+
+// closeBenchRepo удаляет данные бенчмарка из MySQL и закрывает репозиторий
+func closeBenchRepo(b *testing.B, r *VideoRepository) {
+	if r.dialect.Name == MySQL.Name {
+		for _, table := range []string{"media", "tags"} {
+			if _, err := r.db.Exec("DELETE FROM " + table); err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	r.Close()
+}
+
+// legacyRandomUnsent повторяет прежний GetRandomUnsentVideo: сортировку
+// всей таблицы и отдельный запрос тегов на каждое видео
+func legacyRandomUnsent(r *VideoRepository, chatID int64, limit int) ([]models.Video, error) {
+	rows, err := r.db.Query(`
+		SELECT `+videoColumns+`
+		FROM media v
+		WHERE NOT EXISTS (SELECT 1 FROM sent_videos sv WHERE sv.video_id = v.id AND sv.chat_id = ?)
+		ORDER BY `+r.dialect.Random+`
+		LIMIT ?`,
+		chatID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var videos []models.Video
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		videos = append(videos, v)
+	}
+	rows.Close()
+
+	for i := range videos {
+		if videos[i].Tags, err = r.GetVideoTags(videos[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return videos, nil
+}
+
+// populateBatch — видео в одной транзакции при заполнении базы
+const populateBatch = 5000
+
+// populateVideos вставляет видео с ID от first до last, каждому по
+// tagsPerVideo случайных тегов из tags
+func populateVideos(db *sql.DB, d Dialect, first, last, tags, tagsPerVideo int) error {
+	for i := 1; i <= tags; i++ {
+		if _, err := db.Exec(d.InsertIgnore+" INTO tags (id, name) VALUES (?, ?)", i, fmt.Sprintf("tag%d", i)); err != nil {
+			return err
+		}
+	}
+
+	for from := first; from <= last; from += populateBatch {
+		to := from + populateBatch - 1
+		if to > last {
+			to = last
+		}
+		err := inTx(db, func(tx *sql.Tx) error {
+			for id := from; id <= to; id++ {
+				fileID := "bench-" + strconv.Itoa(id)
+				if _, err := tx.Exec("INSERT INTO media (id, file_id, file_unique_id, caption) VALUES (?, ?, ?, ?)",
+					id, fileID, "u-"+fileID, "video "+strconv.Itoa(id)); err != nil {
+					return err
+				}
+				for j := 0; j < tagsPerVideo; j++ {
+					if _, err := tx.Exec(d.InsertIgnore+" INTO video_tags (video_id, tag_id) VALUES (?, ?)", id, 1+rand.Intn(tags)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendUniform доводит долю отправленных в чат видео до seen, отмечая
+// каждое неотправленное независимо с нужной вероятностью
+func sendUniform(r *VideoRepository, chatID int64, size int, seen float64) error {
+	sent, err := sentCount(r, chatID)
+	if err != nil || sent >= int(seen*float64(size)) {
+		return err
+	}
+	p := (seen*float64(size) - float64(sent)) / float64(size-sent)
+
+	rows, err := r.db.Query(`
+		SELECT v.id FROM media v
+		WHERE NOT EXISTS (SELECT 1 FROM sent_videos sv WHERE sv.video_id = v.id AND sv.chat_id = ?)`,
+		chatID,
+	)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		if rand.Float64() < p {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	return markSent(r, chatID, ids)
+}
+
+// sendSampled доводит долю отправленных в чат видео до seen, отправляя
+// видео, которые выбирает GetRandomUnsentVideo
+func sendSampled(r *VideoRepository, chatID int64, size int, seen float64) error {
+	sent, err := sentCount(r, chatID)
+	if err != nil {
+		return err
+	}
+	for target := int(seen * float64(size)); sent < target; {
+		n := target - sent
+		if n > 100 {
+			n = 100
+		}
+		videos, err := r.GetRandomUnsentVideo(chatID, n)
+		if err != nil {
+			return err
+		}
+		ids := make([]int64, len(videos))
+		for i, v := range videos {
+			ids[i] = v.ID
+		}
+		if err := markSent(r, chatID, ids); err != nil {
+			return err
+		}
+		sent += len(ids)
+	}
+	return nil
+}
+
+func sentCount(r *VideoRepository, chatID int64) (int, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM sent_videos WHERE chat_id = ?", chatID).Scan(&n)
+	return n, err
+}
+
+func markSent(r *VideoRepository, chatID int64, ids []int64) error {
+	return inTx(r.db, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.Exec("INSERT INTO sent_videos (chat_id, video_id) VALUES (?, ?)", chatID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
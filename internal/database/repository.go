@@ -6,22 +6,55 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"strings"
 	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"tg-video-bot/pkg/utilities"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-// VideoRepository представляет репозиторий для работы с видео
+// VideoRepository представляет SQL-репозиторий для работы с видео
 type VideoRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
-// NewVideoRepository создает новый экземпляр репозитория
+// NewVideoRepository создает новый экземпляр репозитория поверх MySQL
 func NewVideoRepository(db *sql.DB) *VideoRepository {
-	return &VideoRepository{db: db}
+	return &VideoRepository{db: db, dialect: MySQL}
+}
+
+// NewSQLiteRepository создает новый экземпляр репозитория поверх SQLite
+func NewSQLiteRepository(db *sql.DB) *VideoRepository {
+	return &VideoRepository{db: db, dialect: SQLite}
+}
+
+// videoColumns — колонки media (с псевдонимом v) в порядке, который ожидает scanVideo
+const videoColumns = `v.id, v.media_type, v.file_id, COALESCE(v.file_unique_id, ''), COALESCE(v.caption, ''),
+	v.duration, v.width, v.height, v.mime_type, v.file_size,
+	COALESCE(v.thumb_file_id, ''), COALESCE(v.album_id, 0), COALESCE(v.uploader_id, 0), v.created_at`
+
+// queryRower — общее у *sql.DB и *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rowScanner — общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanVideo читает строку, выбранную через videoColumns
+func scanVideo(row rowScanner) (models.Video, error) {
+	var v models.Video
+	err := row.Scan(&v.ID, &v.Type, &v.FileID, &v.FileUniqueID, &v.Caption,
+		&v.Duration, &v.Width, &v.Height, &v.MimeType, &v.FileSize,
+		&v.ThumbFileID, &v.AlbumID, &v.UploaderID, &v.CreatedAt)
+	return v, err
 }
 
 func InitDB() (*sql.DB, error) {
@@ -32,7 +65,6 @@ func InitDB() (*sql.DB, error) {
 		os.Getenv("DB_PORT"),
 		os.Getenv("DB_NAME"))
 
-	fmt.Println(dsn)
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open DB: %v", err)
@@ -54,7 +86,7 @@ func InitDB() (*sql.DB, error) {
 	}
 
 	// Применяем миграции
-	migrator := NewMigrator(db)
+	migrator := NewMigrator(db, MySQL)
 	if err := migrator.Run(); err != nil {
 		return nil, fmt.Errorf("migrations failed: %v", err)
 	}
@@ -62,28 +94,140 @@ func InitDB() (*sql.DB, error) {
 	return db, nil
 }
 
-// SaveVideo сохраняет видео в базу данных
+// SaveVideo сохраняет видео в базу данных вместе с video.Tags
+// в одной транзакции. Если файл уже сохранен, возвращает *DuplicateVideoError
 func (r *VideoRepository) SaveVideo(video models.Video) (int64, error) {
-	result, err := r.db.Exec(
-		"INSERT INTO videos (file_id, caption) VALUES (?, ?)",
-		video.FileID,
-		video.Caption,
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	if id, ok, err := findDuplicate(tx, video); err != nil {
+		return 0, err
+	} else if ok {
+		return 0, &DuplicateVideoError{VideoID: id}
+	}
+
+	videoID, err := r.insertVideo(tx, video)
+	if err != nil {
+		// Тот же файл мог сохранить параллельный запрос
+		if isUniqueViolation(err) {
+			tx.Rollback()
+			if id, ok, _ := findDuplicate(r.db, video); ok {
+				return 0, &DuplicateVideoError{VideoID: id}
+			}
+		}
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка сохранения видео: %v", err)
+	}
+	return videoID, nil
+}
+
+// SaveAlbum сохраняет новые файлы альбома вместе с тегами в одной транзакции
+func (r *VideoRepository) SaveAlbum(album models.Album) (models.Album, []int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Album{}, nil, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	var fresh []models.Video
+	var duplicates []int64
+	for _, video := range album.Videos {
+		id, ok, err := findDuplicate(tx, video)
+		if err != nil {
+			return models.Album{}, nil, err
+		}
+		if ok {
+			duplicates = append(duplicates, id)
+			continue
+		}
+		fresh = append(fresh, video)
+	}
+	if len(fresh) == 0 {
+		return models.Album{}, duplicates, nil
+	}
+
+	result, err := tx.Exec("INSERT INTO albums (caption) VALUES (?)", album.Caption)
+	if err != nil {
+		return models.Album{}, nil, fmt.Errorf("ошибка сохранения альбома: %v", err)
+	}
+	albumID, err := result.LastInsertId()
+	if err != nil {
+		return models.Album{}, nil, fmt.Errorf("ошибка сохранения альбома: %v", err)
+	}
+
+	saved := models.Album{ID: albumID, Caption: album.Caption}
+	for _, video := range fresh {
+		video.AlbumID = albumID
+		if video.ID, err = r.insertVideo(tx, video); err != nil {
+			return models.Album{}, nil, err
+		}
+		saved.Videos = append(saved.Videos, video)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Album{}, nil, fmt.Errorf("ошибка сохранения альбома: %v", err)
+	}
+	return saved, duplicates, nil
+}
+
+// insertVideo добавляет строку media и теги видео в транзакции tx
+func (r *VideoRepository) insertVideo(tx *sql.Tx, video models.Video) (int64, error) {
+	if video.Type == "" {
+		video.Type = models.MediaVideo
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO media (media_type, file_id, file_unique_id, caption, duration, width, height,
+			mime_type, file_size, thumb_file_id, album_id, uploader_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		video.Type, video.FileID, nullString(video.FileUniqueID), video.Caption,
+		video.Duration, video.Width, video.Height,
+		video.MimeType, video.FileSize, nullString(video.ThumbFileID),
+		nullInt64(video.AlbumID), nullInt64(video.UploaderID),
 	)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сохранения видео: %w", err)
+	}
+
+	videoID, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("ошибка сохранения видео: %v", err)
 	}
 
-	return result.LastInsertId()
+	if err := r.addTags(tx, videoID, video.Tags); err != nil {
+		return 0, err
+	}
+	return videoID, nil
+}
+
+// findDuplicate ищет уже сохраненное видео с тем же file_unique_id или file_id
+func findDuplicate(q queryRower, video models.Video) (int64, bool, error) {
+	var id int64
+	err := q.QueryRow(
+		"SELECT id FROM media WHERE file_unique_id = ? OR file_id = ? LIMIT 1",
+		nullString(video.FileUniqueID), video.FileID,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("ошибка поиска дубликата видео: %v", err)
+	}
+	return id, true, nil
 }
 
 // GetVideoByID возвращает видео по его ID
 func (r *VideoRepository) GetVideoByID(id int64) (models.Video, error) {
-	var video models.Video
-	err := r.db.QueryRow(
-		"SELECT id, file_id, caption FROM videos WHERE id = ?",
+	video, err := scanVideo(r.db.QueryRow(
+		"SELECT "+videoColumns+" FROM media v WHERE v.id = ?",
 		id,
-	).Scan(&video.ID, &video.FileID, &video.Caption)
-	fmt.Println(id)
+	))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -102,11 +246,41 @@ func (r *VideoRepository) GetVideoByID(id int64) (models.Video, error) {
 	return video, nil
 }
 
+// GetAlbumVideos возвращает файлы альбома с тегами в порядке загрузки
+func (r *VideoRepository) GetAlbumVideos(albumID int64) ([]models.Video, error) {
+	rows, err := r.db.Query(`
+		SELECT `+videoColumns+`
+		FROM media v
+		WHERE v.album_id = ?
+		ORDER BY v.id`, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса альбома: %v", err)
+	}
+	defer rows.Close()
+
+	var videos []models.Video
+	for rows.Next() {
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования видео: %v", err)
+		}
+		videos = append(videos, video)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadTags(videos); err != nil {
+		return nil, fmt.Errorf("ошибка получения тегов: %v", err)
+	}
+	return videos, nil
+}
+
 // GetVideosByTag возвращает все видео с указанным тегом
 func (r *VideoRepository) GetVideosByTag(tag string) ([]models.Video, error) {
 	rows, err := r.db.Query(`
-		SELECT v.id, v.file_id, v.caption 
-		FROM videos v
+		SELECT `+videoColumns+`
+		FROM media v
 		JOIN video_tags vt ON v.id = vt.video_id
 		JOIN tags t ON vt.tag_id = t.id
 		WHERE t.name = ?
@@ -118,8 +292,8 @@ func (r *VideoRepository) GetVideosByTag(tag string) ([]models.Video, error) {
 
 	var videos []models.Video
 	for rows.Next() {
-		var video models.Video
-		if err := rows.Scan(&video.ID, &video.FileID, &video.Caption); err != nil {
+		video, err := scanVideo(rows)
+		if err != nil {
 			return nil, fmt.Errorf("ошибка сканирования видео: %v", err)
 		}
 		videos = append(videos, video)
@@ -128,50 +302,138 @@ func (r *VideoRepository) GetVideosByTag(tag string) ([]models.Video, error) {
 	return videos, nil
 }
 
+// FindVideos возвращает видео по запросу тегов, начиная с самых новых
+func (r *VideoRepository) FindVideos(query tagquery.Expr, opts FindOptions) ([]models.Video, int, error) {
+	query, err := r.resolveQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := findCondition(query, opts)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM media v WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("ошибка поиска видео: %v", err)
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	order := "v.created_at DESC, v.id DESC"
+	if opts.UnseenFirst {
+		order = "EXISTS (SELECT 1 FROM sent_videos sv WHERE sv.video_id = v.id AND sv.chat_id = ?), " + order
+		args = append(args, opts.ChatID)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+videoColumns+`
+		FROM media v
+		WHERE `+where+`
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?`,
+		append(args, opts.Limit, opts.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка поиска видео: %v", err)
+	}
+	defer rows.Close()
+
+	var videos []models.Video
+	for rows.Next() {
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("ошибка сканирования видео: %v", err)
+		}
+		videos = append(videos, video)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.loadTags(videos); err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения тегов: %v", err)
+	}
+	return videos, total, nil
+}
+
+// SampleVideos возвращает до opts.Limit случайных видео из тех, что нашел бы
+// FindVideos, и общее число совпадений. Offset и UnseenFirst не учитываются.
+// Совпадения выбираются по случайным смещениям, а не через ORDER BY RAND():
+// под запрос тегов обычно попадает небольшая часть таблицы
+func (r *VideoRepository) SampleVideos(query tagquery.Expr, opts FindOptions) ([]models.Video, int, error) {
+	query, err := r.resolveQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := findCondition(query, opts)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM media v WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("ошибка поиска видео: %v", err)
+	}
+
+	var videos []models.Video
+	for _, offset := range randomOffsets(total, opts.Limit) {
+		video, err := scanVideo(r.db.QueryRow(`
+			SELECT `+videoColumns+`
+			FROM media v
+			WHERE `+where+`
+			ORDER BY v.id
+			LIMIT 1 OFFSET ?`,
+			append(args, offset)...,
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			// Видео удалили между запросами
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("ошибка сканирования видео: %v", err)
+		}
+		videos = append(videos, video)
+	}
+
+	if err := r.loadTags(videos); err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения тегов: %v", err)
+	}
+	return videos, total, nil
+}
+
 // AddTagsToVideo добавляет теги к видео
 func (r *VideoRepository) AddTagsToVideo(videoID int64, tags []string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("ошибка начала транзакции: %v", err)
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	defer tx.Rollback()
+
+	if err := r.addTags(tx, videoID, tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
+// addTags создает недостающие теги и связывает их с видео в транзакции tx
+func (r *VideoRepository) addTags(tx *sql.Tx, videoID int64, tags []string) error {
 	for _, tagName := range tags {
-		// Нормализуем тег
-		tagName = strings.TrimSpace(strings.ToLower(tagName))
+		tagName = utilities.NormalizeTag(tagName)
 		if tagName == "" {
 			continue
 		}
 
-		// Добавляем тег или получаем существующий ID
-		var tagID int64
-		err = tx.QueryRow(
-			"SELECT id FROM tags WHERE name = ?",
-			tagName,
-		).Scan(&tagID)
-
+		// Псевдонимы сводятся к существующему тегу
+		tagID, _, ok, err := lookupTag(tx, tagName)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				result, err := tx.Exec(
-					"INSERT INTO tags (name) VALUES (?)",
-					tagName,
-				)
-				if err != nil {
-					return fmt.Errorf("ошибка добавления тега: %v", err)
-				}
-				tagID, _ = result.LastInsertId()
-			} else {
-				return fmt.Errorf("ошибка запроса тега: %v", err)
+			return err
+		}
+		if !ok {
+			if tagID, err = insertTag(tx, tagName); err != nil {
+				return err
 			}
 		}
 
 		// Связываем видео и тег
 		_, err = tx.Exec(
-			"INSERT IGNORE INTO video_tags (video_id, tag_id) VALUES (?, ?)",
+			r.dialect.InsertIgnore+" INTO video_tags (video_id, tag_id) VALUES (?, ?)",
 			videoID,
 			tagID,
 		)
@@ -180,7 +442,7 @@ func (r *VideoRepository) AddTagsToVideo(videoID int64, tags []string) error {
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 // GetVideoTags возвращает все теги для видео
@@ -208,6 +470,78 @@ func (r *VideoRepository) GetVideoTags(videoID int64) ([]string, error) {
 	return tags, nil
 }
 
+// tagsBatchSize ограничивает число ID в одном запросе loadTags
+const tagsBatchSize = 500
+
+// loadTags загружает теги для списка видео пачками вместо запроса на каждое видео
+func (r *VideoRepository) loadTags(videos []models.Video) error {
+	index := make(map[int64][]int, len(videos))
+	for i := range videos {
+		index[videos[i].ID] = append(index[videos[i].ID], i)
+	}
+
+	for start := 0; start < len(videos); start += tagsBatchSize {
+		end := start + tagsBatchSize
+		if end > len(videos) {
+			end = len(videos)
+		}
+		args := make([]interface{}, 0, end-start)
+		for _, v := range videos[start:end] {
+			args = append(args, v.ID)
+		}
+
+		rows, err := r.db.Query(`
+			SELECT vt.video_id, t.name
+			FROM video_tags vt
+			JOIN tags t ON t.id = vt.tag_id
+			WHERE vt.video_id IN (`+placeholders(len(args))+`)`,
+			args...,
+		)
+		if err != nil {
+			return fmt.Errorf("ошибка запроса тегов: %v", err)
+		}
+		for rows.Next() {
+			var videoID int64
+			var tag string
+			if err := rows.Scan(&videoID, &tag); err != nil {
+				rows.Close()
+				return fmt.Errorf("ошибка сканирования тега: %v", err)
+			}
+			for _, i := range index[videoID] {
+				videos[i].Tags = append(videos[i].Tags, tag)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholders возвращает "?, ?, ..." для n параметров
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// randomOffsets возвращает до n разных случайных чисел из [0, total)
+func randomOffsets(total, n int) []int {
+	if n > total {
+		n = total
+	}
+	offsets := make([]int, 0, n)
+	taken := make(map[int]bool, n)
+	for len(offsets) < n {
+		offset := rand.Intn(total)
+		if !taken[offset] {
+			taken[offset] = true
+			offsets = append(offsets, offset)
+		}
+	}
+	return offsets
+}
+
 // IsVideoSent проверяет, отправлялось ли видео в указанный чат
 func (r *VideoRepository) IsVideoSent(chatID, videoID int64) bool {
 	var exists bool
@@ -226,12 +560,16 @@ func (r *VideoRepository) IsVideoSent(chatID, videoID int64) bool {
 	return exists
 }
 
-// MarkVideoSent отмечает видео как отправленное в чат
+// MarkVideoSent отмечает видео как отправленное в чат. Повторная отправка
+// обновляет sent_at, по нему выбираются видео для повторов
 func (r *VideoRepository) MarkVideoSent(chatID, videoID int64) error {
 	_, err := r.db.Exec(
-		"INSERT INTO sent_videos (chat_id, video_id) VALUES (?, ?)",
+		r.dialect.Upsert("sent_videos",
+			[]string{"chat_id", "video_id"},
+			[]string{"chat_id", "video_id", "sent_at"}),
 		chatID,
 		videoID,
+		time.Now().UTC(),
 	)
 	return err
 }
@@ -268,57 +606,172 @@ func (r *VideoRepository) GetPopularTags(limit int) ([]string, error) {
 func (r *VideoRepository) VideoExists(id int64) (bool, error) {
 	var exists bool
 	err := r.db.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM videos WHERE id = ?)",
+		"SELECT EXISTS(SELECT 1 FROM media WHERE id = ?)",
 		id,
 	).Scan(&exists)
 
 	return exists, err
 }
 
-// GetRandomUnsentVideo возвращает случайное видео, которое еще не было отправлено в указанный чат
+// randomProbes — сколько случайных ID проверяется на каждое запрошенное
+// видео в первом запросе; каждый следующий запрос проверяет вдвое больше,
+// но не больше maxRandomProbes
+const (
+	randomProbes    = 16
+	maxRandomProbes = 4096
+)
+
+// GetRandomUnsentVideo возвращает случайные видео, которые еще не были
+// отправлены в указанный чат. ORDER BY RAND() сортировал всю таблицу на
+// каждый запрос, поэтому видео выбираются выборкой с отклонением: берутся
+// случайные ID между минимальным и максимальным, и одним запросом по
+// первичному ключу проверяется, какие из них существуют и не отправлены.
+// Подходящие ID принимаются в порядке выбора, остальные отбрасываются, так
+// что каждое неотправленное видео равновероятно независимо от того, как
+// расположены в таблице пропуски и уже отправленные видео. Чем больше чат
+// уже видел, тем больше проб нужно, поэтому их число удваивается от запроса
+// к запросу. Когда пробы покрыли четверть диапазона ID, дешевле один раз
+// пройти индекс целиком, и оставшиеся видео выбираются из полного списка
+// неотправленных ID. Замеры: go test -bench RandomUnsent ./internal/database
 func (r *VideoRepository) GetRandomUnsentVideo(chatID int64, limit int) ([]models.Video, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var minID, maxID sql.NullInt64
+	// Отдельные подзапросы: MIN и MAX в одном SELECT SQLite считает сканированием
+	err := r.db.QueryRow("SELECT (SELECT MIN(id) FROM media), (SELECT MAX(id) FROM media)").Scan(&minID, &maxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get random video: %v", err)
+	}
+	if !minID.Valid {
+		return nil, nil
+	}
+	span := maxID.Int64 - minID.Int64 + 1
+
 	var videos []models.Video
+	taken := make(map[int64]bool, limit)
+	probes, drawn := limit*randomProbes, int64(0)
+	for len(videos) < limit && drawn < span/4 {
+		if probes > maxRandomProbes {
+			probes = maxRandomProbes
+		}
+		draws := make([]int64, probes)
+		for i := range draws {
+			draws[i] = minID.Int64 + rand.Int63n(span)
+		}
+		found, err := r.unsentByIDs(chatID, draws)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get random video: %v", err)
+		}
+		for _, id := range draws {
+			if v, ok := found[id]; ok && !taken[id] && len(videos) < limit {
+				taken[id] = true
+				videos = append(videos, v)
+			}
+		}
+		drawn += int64(probes)
+		probes *= 2
+	}
+
+	if len(videos) < limit {
+		rest, err := r.randomUnsentScan(chatID, limit-len(videos), taken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get random video: %v", err)
+		}
+		videos = append(videos, rest...)
+	}
+
+	if err := r.loadTags(videos); err != nil {
+		return nil, fmt.Errorf("failed to get video tags: %v", err)
+	}
+	return videos, nil
+}
+
+// unsentByIDs возвращает те из видео ids, что существуют и еще не
+// отправлены в чат
+func (r *VideoRepository) unsentByIDs(chatID int64, ids []int64) (map[int64]models.Video, error) {
+	args := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, chatID)
 
 	rows, err := r.db.Query(`
-		SELECT v.id, v.file_id, v.caption 
-		FROM videos v
-		WHERE NOT EXISTS (
-			SELECT 1 FROM sent_videos sv 
-			WHERE sv.video_id = v.id AND sv.chat_id = ?
-		)
-		ORDER BY RAND()
-		LIMIT ?`,
-		chatID, limit,
+		SELECT `+videoColumns+`
+		FROM media v
+		WHERE v.id IN (`+placeholders(len(ids))+`)
+			AND NOT EXISTS (SELECT 1 FROM sent_videos sv WHERE sv.video_id = v.id AND sv.chat_id = ?)`,
+		args...,
 	)
-
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return videos, fmt.Errorf("no unsent videos available")
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := make(map[int64]models.Video)
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			return nil, err
 		}
-		return videos, fmt.Errorf("failed to get random video: %v", err)
+		videos[v.ID] = v
 	}
+	return videos, rows.Err()
+}
 
+// randomUnsentScan выбирает n случайных неотправленных в чат видео, кроме
+// skip, из полного списка неотправленных ID. Нужен, только когда чат видел
+// почти все видео или в диапазоне ID много пропусков
+func (r *VideoRepository) randomUnsentScan(chatID int64, n int, skip map[int64]bool) ([]models.Video, error) {
+	rows, err := r.db.Query(`
+		SELECT v.id
+		FROM media v
+		WHERE NOT EXISTS (SELECT 1 FROM sent_videos sv WHERE sv.video_id = v.id AND sv.chat_id = ?)`,
+		chatID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var unsent []int64
 	for rows.Next() {
-		var v models.Video
-		if err := rows.Scan(&v.ID, &v.FileID, &v.Caption); err != nil {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		tags, err := r.GetVideoTags(v.ID)
-		if err != nil {
-			return videos, fmt.Errorf("failed to get video tags: %v", err)
+		if !skip[id] {
+			unsent = append(unsent, id)
 		}
-		v.Tags = tags
-		videos = append(videos, v)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil || len(unsent) == 0 {
+		return nil, err
 	}
 
+	ids := make([]int64, 0, n)
+	for _, i := range randomOffsets(len(unsent), n) {
+		ids = append(ids, unsent[i])
+	}
+	found, err := r.unsentByIDs(chatID, ids)
+	if err != nil {
+		return nil, err
+	}
+	videos := make([]models.Video, 0, len(ids))
+	for _, id := range ids {
+		if v, ok := found[id]; ok {
+			videos = append(videos, v)
+		}
+	}
 	return videos, nil
 }
 
 func (r *VideoRepository) GetAllVideos() ([]models.Video, error) {
 	rows, err := r.db.Query(`
-		SELECT id, file_id, caption 
-		FROM videos 
-		ORDER BY created_at DESC`)
+		SELECT ` + videoColumns + `
+		FROM media v
+		ORDER BY v.created_at DESC, v.id DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -326,8 +779,8 @@ func (r *VideoRepository) GetAllVideos() ([]models.Video, error) {
 
 	var videos []models.Video
 	for rows.Next() {
-		var v models.Video
-		if err := rows.Scan(&v.ID, &v.FileID, &v.Caption); err != nil {
+		v, err := scanVideo(rows)
+		if err != nil {
 			return nil, err
 		}
 		videos = append(videos, v)
@@ -337,10 +790,25 @@ func (r *VideoRepository) GetAllVideos() ([]models.Video, error) {
 }
 
 func (r *VideoRepository) DeleteVideo(id int64) error {
-	_, err := r.db.Exec("DELETE FROM videos WHERE id = ?", id)
+	_, err := r.db.Exec("DELETE FROM media WHERE id = ?", id)
 	return err
 }
 
+// Close закрывает соединение с базой данных
+func (r *VideoRepository) Close() error {
+	return r.db.Close()
+}
+
+// nullString сохраняет пустую строку как NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt64 сохраняет 0 как NULL
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
 func retry(attempts int, delay time.Duration, fn func() error) error {
 	var err error
 	for i := 0; i < attempts; i++ {
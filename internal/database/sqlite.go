@@ -0,0 +1,42 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+const defaultSQLitePath = "tg-video-bot.db"
+
+// InitSQLite открывает файл базы SQLite и применяет миграции
+func InitSQLite(path string) (*sql.DB, error) {
+	if path == "" {
+		path = defaultSQLitePath
+	}
+
+	// busy_timeout должен идти первым, foreign_keys нужен для ON DELETE CASCADE
+	dsn := "file:" + path +
+		"?_pragma=busy_timeout(10000)" +
+		"&_pragma=journal_mode(wal)" +
+		"&_pragma=foreign_keys(1)" +
+		"&_txlock=immediate" +
+		"&_timefmt=sqlite"
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %v", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to connect to DB: %v", err)
+	}
+
+	migrator := NewMigrator(db, SQLite)
+	if err := migrator.Run(); err != nil {
+		return nil, fmt.Errorf("migrations failed: %v", err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"tg-video-bot/internal/models"
+)
+
+// GetChatState возвращает состояние диалога в чате
+func (r *VideoRepository) GetChatState(chatID int64) (models.ChatState, bool, error) {
+	state := models.ChatState{ChatID: chatID}
+	var data sql.NullString
+	err := r.db.QueryRow(
+		"SELECT user_id, state, data, expires_at FROM chat_states WHERE chat_id = ?",
+		chatID,
+	).Scan(&state.UserID, &state.State, &data, &state.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, fmt.Errorf("ошибка получения состояния чата: %v", err)
+	}
+
+	if data.Valid && data.String != "" {
+		if err := json.Unmarshal([]byte(data.String), &state.Data); err != nil {
+			return state, false, fmt.Errorf("ошибка разбора состояния чата: %v", err)
+		}
+	}
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+
+	return state, true, nil
+}
+
+// SaveChatState сохраняет состояние диалога, заменяя предыдущее
+func (r *VideoRepository) SaveChatState(state models.ChatState) error {
+	data, err := json.Marshal(state.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		r.dialect.Upsert("chat_states",
+			[]string{"chat_id"},
+			[]string{"chat_id", "user_id", "state", "data", "expires_at"}),
+		state.ChatID, state.UserID, state.State, string(data), state.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения состояния чата: %v", err)
+	}
+	return nil
+}
+
+// DeleteChatState завершает диалог в чате
+func (r *VideoRepository) DeleteChatState(chatID int64) error {
+	_, err := r.db.Exec("DELETE FROM chat_states WHERE chat_id = ?", chatID)
+	return err
+}
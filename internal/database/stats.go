@@ -0,0 +1,178 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"tg-video-bot/internal/models"
+	"time"
+)
+
+// RecordDelivery пишет событие отправки видео в чат
+func (r *VideoRepository) RecordDelivery(chatID, videoID int64, source string) error {
+	_, err := r.db.Exec(
+		"INSERT INTO video_deliveries (chat_id, video_id, source) VALUES (?, ?, ?)",
+		chatID, videoID, source,
+	)
+	return err
+}
+
+// RecordTagRequest пишет событие поиска по тегу
+func (r *VideoRepository) RecordTagRequest(chatID int64, tag string) error {
+	_, err := r.db.Exec(
+		"INSERT INTO tag_requests (chat_id, tag) VALUES (?, ?)",
+		chatID, tag,
+	)
+	return err
+}
+
+// GetStats собирает статистику за период начиная с since. limit ограничивает
+// длину каждого топа
+func (r *VideoRepository) GetStats(since time.Time, limit int) (models.Stats, error) {
+	stats := models.Stats{Since: since}
+	// Метки времени в базе хранятся в UTC
+	since = since.UTC()
+
+	err := r.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM media),
+			(SELECT COUNT(*) FROM tags),
+			(SELECT COUNT(DISTINCT chat_id) FROM sent_videos)
+	`).Scan(&stats.TotalVideos, &stats.TotalTags, &stats.TotalChats)
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета итогов: %v", err)
+	}
+
+	err = r.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT chat_id)
+		FROM video_deliveries
+		WHERE delivered_at >= ?
+	`, since).Scan(&stats.Sent, &stats.ActiveChats)
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета отправок: %v", err)
+	}
+
+	err = r.queryStats(`
+		SELECT DATE(delivered_at) AS day, COUNT(*)
+		FROM video_deliveries
+		WHERE delivered_at >= ?
+		GROUP BY DATE(delivered_at)
+		ORDER BY day`,
+		[]interface{}{since},
+		func(rows *sql.Rows) error {
+			var d models.DayCount
+			if err := rows.Scan(&d.Day, &d.Count); err != nil {
+				return err
+			}
+			// MySQL с parseTime отдает дату как время в RFC 3339
+			if len(d.Day) > 10 {
+				d.Day = d.Day[:10]
+			}
+			stats.PerDay = append(stats.PerDay, d)
+			return nil
+		})
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета отправок по дням: %v", err)
+	}
+
+	err = r.queryStats(`
+		SELECT d.video_id, COALESCE(v.caption, ''), COUNT(*) AS cnt
+		FROM video_deliveries d
+		LEFT JOIN media v ON v.id = d.video_id
+		WHERE d.delivered_at >= ?
+		GROUP BY d.video_id, v.caption
+		ORDER BY cnt DESC, d.video_id
+		LIMIT ?`,
+		[]interface{}{since, limit},
+		func(rows *sql.Rows) error {
+			var v models.VideoCount
+			if err := rows.Scan(&v.VideoID, &v.Caption, &v.Count); err != nil {
+				return err
+			}
+			stats.TopVideos = append(stats.TopVideos, v)
+			return nil
+		})
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета популярных видео: %v", err)
+	}
+
+	err = r.queryStats(`
+		SELECT t.name, COUNT(*) AS cnt
+		FROM video_deliveries d
+		JOIN video_tags vt ON vt.video_id = d.video_id
+		JOIN tags t ON t.id = vt.tag_id
+		WHERE d.delivered_at >= ?
+		GROUP BY t.name
+		ORDER BY cnt DESC, t.name
+		LIMIT ?`,
+		[]interface{}{since, limit},
+		func(rows *sql.Rows) error {
+			var t models.TagCount
+			if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+				return err
+			}
+			stats.TopTags = append(stats.TopTags, t)
+			return nil
+		})
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета популярных тегов: %v", err)
+	}
+
+	err = r.queryStats(`
+		SELECT tag, COUNT(*) AS cnt
+		FROM tag_requests
+		WHERE requested_at >= ?
+		GROUP BY tag
+		ORDER BY cnt DESC, tag
+		LIMIT ?`,
+		[]interface{}{since, limit},
+		func(rows *sql.Rows) error {
+			var t models.TagCount
+			if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+				return err
+			}
+			stats.TopRequests = append(stats.TopRequests, t)
+			return nil
+		})
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета запросов тегов: %v", err)
+	}
+
+	err = r.queryStats(`
+		SELECT d.chat_id, COUNT(*) AS cnt,
+			(SELECT COUNT(*) FROM sent_videos s WHERE s.chat_id = d.chat_id)
+		FROM video_deliveries d
+		WHERE d.delivered_at >= ?
+		GROUP BY d.chat_id
+		ORDER BY cnt DESC, d.chat_id
+		LIMIT ?`,
+		[]interface{}{since, limit},
+		func(rows *sql.Rows) error {
+			var c models.ChatCount
+			if err := rows.Scan(&c.ChatID, &c.Sent, &c.Seen); err != nil {
+				return err
+			}
+			stats.TopChats = append(stats.TopChats, c)
+			return nil
+		})
+	if err != nil {
+		return stats, fmt.Errorf("ошибка подсчета потребления по чатам: %v", err)
+	}
+
+	return stats, nil
+}
+
+// queryStats выполняет запрос и передает каждую строку в scan
+func (r *VideoRepository) queryStats(query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
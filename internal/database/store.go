@@ -0,0 +1,144 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+	"time"
+)
+
+// VideoStore описывает хранилище видео, тегов и истории отправок,
+// с которым работают обработчики бота
+type VideoStore interface {
+	SaveVideo(video models.Video) (int64, error)
+	// SaveAlbum сохраняет файлы альбома одной транзакцией. Уже сохраненные
+	// ранее файлы пропускаются, их ID возвращаются в duplicates. Если новых
+	// файлов нет, альбом не создается и saved.ID равен 0
+	SaveAlbum(album models.Album) (saved models.Album, duplicates []int64, err error)
+	GetAlbumVideos(albumID int64) ([]models.Video, error)
+	GetVideoByID(id int64) (models.Video, error)
+	GetVideosByTag(tag string) ([]models.Video, error)
+	// FindVideos возвращает страницу видео, подходящих под запрос тегов,
+	// и общее число совпадений. query == nil — без условия по тегам
+	FindVideos(query tagquery.Expr, opts FindOptions) ([]models.Video, int, error)
+	// SampleVideos возвращает до opts.Limit случайных видео из результатов
+	// FindVideos и общее число совпадений
+	SampleVideos(query tagquery.Expr, opts FindOptions) ([]models.Video, int, error)
+	AddTagsToVideo(videoID int64, tags []string) error
+	GetVideoTags(videoID int64) ([]string, error)
+	IsVideoSent(chatID, videoID int64) bool
+	// MarkVideoSent отмечает видео отправленным в чат; при повторной
+	// отправке обновляет время просмотра
+	MarkVideoSent(chatID, videoID int64) error
+	// ResetSentVideos очищает историю отправок чата и возвращает число
+	// удаленных записей
+	ResetSentVideos(chatID int64) (int, error)
+	// GetReplayVideos возвращает уже отправленные в чат видео, начиная с
+	// давно просмотренных. Ненулевой seenBefore оставляет только видео,
+	// просмотренные раньше этого момента
+	GetReplayVideos(chatID int64, seenBefore time.Time, limit int) ([]models.Video, error)
+	GetPopularTags(limit int) ([]string, error)
+	// ListTags возвращает страницу тегов с числом видео у каждого и общее
+	// число тегов
+	ListTags(limit, offset int) ([]models.TagCount, int, error)
+	// RenameTag, MergeTags, DeleteTag и RemoveTagsFromVideo находят теги
+	// и по псевдонимам, как LookupTag.
+	// RenameTag переименовывает тег, старое имя становится псевдонимом;
+	// ErrTagExists, если новое имя занято
+	RenameTag(oldName, newName string) error
+	// MergeTags переносит видео тегов from на тег into, удаляет from,
+	// оставляя их имена псевдонимами into, и возвращает число видео,
+	// получивших тег into
+	MergeTags(from []string, into string) (int, error)
+	// DeleteTag удаляет тег и возвращает число видео, с которых он снят
+	DeleteTag(name string) (int, error)
+	// RemoveTagsFromVideo снимает теги с видео и возвращает число снятых
+	RemoveTagsFromVideo(videoID int64, tags []string) (int, error)
+	// AddTagAlias делает alias псевдонимом тега tag: при сохранении и
+	// поиске alias заменяется на tag
+	AddTagAlias(alias, tag string) error
+	DeleteTagAlias(alias string) error
+	ListTagAliases() ([]models.TagAlias, error)
+	// LookupTag возвращает каноничное имя тега по имени или псевдониму,
+	// как при сохранении тегов
+	LookupTag(name string) (string, bool, error)
+	// ResolveTag возвращает каноничное имя тега по имени, псевдониму или
+	// записи другим алфавитом, как при поиске
+	ResolveTag(name string) (string, bool, error)
+	VideoExists(id int64) (bool, error)
+	GetRandomUnsentVideo(chatID int64, limit int) ([]models.Video, error)
+	GetAllVideos() ([]models.Video, error)
+	DeleteVideo(id int64) error
+	Close() error
+}
+
+// UpdateLog хранит прогресс обработки апдейтов Telegram, чтобы после
+// перезапуска не терять и не обрабатывать повторно уже полученные апдейты
+type UpdateLog interface {
+	GetUpdateOffset(botID int64) (int, error)
+	SaveUpdateOffset(botID int64, lastUpdateID int) error
+	IsUpdateProcessed(botID int64, updateID int) (bool, error)
+	MarkUpdateProcessed(botID int64, updateID int) error
+}
+
+// UserStore хранит роли пользователей
+type UserStore interface {
+	GetUserRole(userID int64) (models.Role, error)
+	SetUserRole(userID int64, role models.Role, grantedBy int64) error
+	DeleteUserRole(userID int64) error
+	ListUserRoles() ([]models.UserRole, error)
+}
+
+// StatsStore хранит события использования бота и строит по ним статистику
+type StatsStore interface {
+	RecordDelivery(chatID, videoID int64, source string) error
+	RecordTagRequest(chatID int64, tag string) error
+	GetStats(since time.Time, limit int) (models.Stats, error)
+}
+
+// StateStore хранит состояние диалогов по чатам
+type StateStore interface {
+	// GetChatState возвращает состояние чата, в том числе истекшее;
+	// ok == false, если состояния нет
+	GetChatState(chatID int64) (state models.ChatState, ok bool, err error)
+	SaveChatState(state models.ChatState) error
+	DeleteChatState(chatID int64) error
+}
+
+// Store объединяет все возможности хранилища, которые нужны боту
+type Store interface {
+	VideoStore
+	UpdateLog
+	UserStore
+	StatsStore
+	StateStore
+}
+
+var (
+	_ Store = (*VideoRepository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// OpenStore открывает хранилище, выбранное переменной окружения DB_DRIVER
+// (mysql по умолчанию, sqlite или memory)
+func OpenStore() (Store, error) {
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "mysql":
+		db, err := InitDB()
+		if err != nil {
+			return nil, err
+		}
+		return NewVideoRepository(db), nil
+	case "sqlite":
+		db, err := InitSQLite(os.Getenv("SQLITE_PATH"))
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteRepository(db), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q", driver)
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"strings"
+	"tg-video-bot/internal/tagquery"
+)
+
+// FindOptions — параметры поиска по запросу тегов
+type FindOptions struct {
+	// ChatID и ExcludeSent исключают видео, уже отправленные в чат
+	ChatID      int64
+	ExcludeSent bool
+	// UnseenFirst ставит вперед видео, которые еще не отправлялись в ChatID
+	UnseenFirst bool
+	// Caption ищет подстроку в подписи: видео подходит, если совпал запрос
+	// тегов или подпись
+	Caption string
+	Limit   int
+	Offset  int
+}
+
+// findCondition строит условие WHERE для FindVideos. query == nil и
+// пустой Caption подходят под любое видео
+func findCondition(query tagquery.Expr, opts FindOptions) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if query != nil {
+		cond, a := tagQueryCondition(query)
+		conds = append(conds, cond)
+		args = append(args, a...)
+	}
+	if opts.Caption != "" {
+		// Регистр не учитывается в MySQL, в SQLite — только для латиницы
+		conds = append(conds, "v.caption LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(opts.Caption)+"%")
+	}
+
+	where := "1 = 1"
+	if len(conds) > 0 {
+		where = "(" + strings.Join(conds, " OR ") + ")"
+	}
+	if opts.ExcludeSent {
+		where += " AND NOT EXISTS (SELECT 1 FROM sent_videos sv WHERE sv.video_id = v.id AND sv.chat_id = ?)"
+		args = append(args, opts.ChatID)
+	}
+	return where, args
+}
+
+// tagQueryCondition строит условие WHERE для видео v по запросу тегов.
+// Каждый тег проверяется подзапросом EXISTS, шаблоны — через LIKE
+func tagQueryCondition(e tagquery.Expr) (string, []interface{}) {
+	switch x := e.(type) {
+	case tagquery.Term:
+		cond := "t.name = ?"
+		arg := x.Tag
+		if x.IsPattern() {
+			cond = "t.name LIKE ? ESCAPE '!'"
+			arg = likePattern(x.Tag)
+		}
+		return `EXISTS (SELECT 1 FROM video_tags vt JOIN tags t ON t.id = vt.tag_id
+			WHERE vt.video_id = v.id AND ` + cond + `)`, []interface{}{arg}
+
+	case tagquery.Not:
+		cond, args := tagQueryCondition(x.X)
+		return "NOT " + cond, args
+
+	case tagquery.And:
+		return joinConditions([]tagquery.Expr(x), " AND ")
+
+	case tagquery.Or:
+		return joinConditions([]tagquery.Expr(x), " OR ")
+	}
+	return "1 = 0", nil
+}
+
+func joinConditions(exprs []tagquery.Expr, op string) (string, []interface{}) {
+	conds := make([]string, len(exprs))
+	var args []interface{}
+	for i, e := range exprs {
+		var a []interface{}
+		conds[i], a = tagQueryCondition(e)
+		args = append(args, a...)
+	}
+	return "(" + strings.Join(conds, op) + ")", args
+}
+
+// likePattern переводит шаблон с "*" в LIKE. Экранирующий символ "!"
+// одинаково работает в MySQL и SQLite, в отличие от обратной косой черты
+func likePattern(pattern string) string {
+	return strings.ReplaceAll(likeEscaper.Replace(pattern), "*", "%")
+}
+
+// likeEscaper экранирует спецсимволы LIKE
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/internal/tagquery"
+)
+
+// TestFindVideosTagQuery проверяет условие WHERE из tagQueryCondition на
+// SQLite и сверяет его с MemoryStore, который применяет Expr.Match. Теги
+// со спецсимволами LIKE должны совпадать только буквально
+func TestFindVideosTagQuery(t *testing.T) {
+	videos := map[string][]string{
+		"v1": {"a_b"},
+		"v2": {"axb"},
+		"v3": {"50%"},
+		"v4": {"500"},
+		"v5": {"c!d"},
+		"v6": {"cxd"},
+		"v7": {"котики", "смешные"},
+		"v8": {"котики"},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"a_b", "v1"},
+		{"a_*", "v1"},
+		{"*_*", "v1"},
+		{"50%", "v3"},
+		{"50*", "v3 v4"},
+		{"*%", "v3"},
+		{"c!d", "v5"},
+		{"c!*", "v5"},
+		{"c*d", "v5 v6"},
+		{"котики -смешные", "v8"},
+		{"-котики", "v1 v2 v3 v4 v5 v6"},
+		{"смешные | a_b", "v1 v7"},
+		{"-(a_b | axb | 50* | c*d)", "v7 v8"},
+		{"NOT котики AND c* OR смешные", "v5 v6 v7"},
+	}
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": newTestSQLite(t),
+	}
+	for name, store := range stores {
+		for fileID, tags := range videos {
+			if _, err := store.SaveVideo(models.Video{FileID: fileID, FileUniqueID: "u-" + fileID, Tags: tags}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tt := range tests {
+			query, err := tagquery.Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			found, total, err := store.FindVideos(query, FindOptions{Limit: len(videos)})
+			if err != nil {
+				t.Fatalf("%s: FindVideos(%q): %v", name, tt.query, err)
+			}
+
+			got := make([]string, len(found))
+			for i, v := range found {
+				got[i] = v.FileID
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != tt.want || total != len(found) {
+				t.Errorf("%s: FindVideos(%q) = %q (total %d), want %q", name, tt.query, got, total, tt.want)
+			}
+		}
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"муз*", "муз%"},
+		{"a_*", "a!_%"},
+		{"50%*", "50!%%"},
+		{"c!*d", "c!!%d"},
+		{"*", "%"},
+	}
+	for _, tt := range tests {
+		if got := likePattern(tt.pattern); got != tt.want {
+			t.Errorf("likePattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tg-video-bot/internal/models"
+	"tg-video-bot/pkg/utilities"
+)
+
+// ListTags возвращает страницу тегов с числом видео, начиная с самых
+// используемых. Теги без видео тоже попадают в список, чтобы их можно
+// было найти и удалить
+func (r *VideoRepository) ListTags(limit, offset int) ([]models.TagCount, int, error) {
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM tags").Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("ошибка подсчета тегов: %v", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT t.name, COUNT(vt.video_id) AS count
+		FROM tags t
+		LEFT JOIN video_tags vt ON t.id = vt.tag_id
+		GROUP BY t.id, t.name
+		ORDER BY count DESC, t.name
+		LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка запроса тегов: %v", err)
+	}
+	defer rows.Close()
+
+	var tags []models.TagCount
+	for rows.Next() {
+		var tc models.TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, 0, fmt.Errorf("ошибка сканирования тега: %v", err)
+		}
+		tags = append(tags, tc)
+	}
+	return tags, total, rows.Err()
+}
+
+// RenameTag переименовывает тег, старое имя остается его псевдонимом.
+// oldName может быть и псевдонимом тега. Если имя newName занято другим
+// тегом или его псевдонимом, возвращает ErrTagExists: такие теги
+// объединяет MergeTags
+func (r *VideoRepository) RenameTag(oldName, newName string) error {
+	newName = utilities.NormalizeTag(newName)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, oldName, err := existingTag(tx, oldName)
+	if err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	// Собственный псевдоним можно сделать основным именем
+	var aliasOf int64
+	err = tx.QueryRow("SELECT tag_id FROM tag_aliases WHERE alias = ?", newName).Scan(&aliasOf)
+	switch {
+	case err == nil && aliasOf != id:
+		return fmt.Errorf("%w: %s", ErrTagExists, newName)
+	case err == nil:
+		if _, err := tx.Exec("DELETE FROM tag_aliases WHERE alias = ?", newName); err != nil {
+			return fmt.Errorf("ошибка переименования тега: %v", err)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("ошибка переименования тега: %v", err)
+	}
+
+	_, err = tx.Exec("UPDATE tags SET name = ?, translit_key = ? WHERE id = ?", newName, utilities.TranslitKey(newName), id)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", ErrTagExists, newName)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка переименования тега: %v", err)
+	}
+	if err := r.saveAlias(tx, oldName, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeTags переносит видео тегов from на тег into и удаляет теги from;
+// их имена и псевдонимы становятся псевдонимами into. Теги from и into
+// ищутся и по псевдонимам, тег into создается, если его не было.
+// Возвращает число видео, которым тег into добавлен
+func (r *VideoRepository) MergeTags(from []string, into string) (int, error) {
+	into = utilities.NormalizeTag(into)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	intoID, _, ok, err := lookupTag(tx, into)
+	if err == nil && !ok {
+		intoID, err = insertTag(tx, into)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка объединения тегов: %w", err)
+	}
+
+	var moved int64
+	for _, name := range from {
+		fromID, name, err := existingTag(tx, name)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка объединения тегов: %w", err)
+		}
+		if fromID == intoID {
+			continue
+		}
+
+		result, err := tx.Exec(
+			r.dialect.InsertIgnore+" INTO video_tags (video_id, tag_id) SELECT video_id, ? FROM video_tags WHERE tag_id = ?",
+			intoID, fromID,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка переноса видео: %v", err)
+		}
+		n, _ := result.RowsAffected()
+		moved += n
+
+		if _, err := tx.Exec("UPDATE tag_aliases SET tag_id = ? WHERE tag_id = ?", intoID, fromID); err != nil {
+			return 0, fmt.Errorf("ошибка переноса псевдонимов: %v", err)
+		}
+		if err := deleteTag(tx, fromID); err != nil {
+			return 0, err
+		}
+		if err := r.saveAlias(tx, name, intoID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(moved), nil
+}
+
+// DeleteTag удаляет тег (name — имя или псевдоним) и возвращает число
+// видео, с которых он снят
+func (r *VideoRepository) DeleteTag(name string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, _, err := existingTag(tx, name)
+	if err != nil {
+		return 0, err
+	}
+	var videos int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM video_tags WHERE tag_id = ?", id).Scan(&videos); err != nil {
+		return 0, fmt.Errorf("ошибка удаления тега: %v", err)
+	}
+	if err := deleteTag(tx, id); err != nil {
+		return 0, err
+	}
+
+	return videos, tx.Commit()
+}
+
+// RemoveTagsFromVideo снимает теги с видео (по именам или псевдонимам) и
+// возвращает число снятых. Сами теги остаются, даже если у них больше нет видео
+func (r *VideoRepository) RemoveTagsFromVideo(videoID int64, tags []string) (int, error) {
+	names := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		if tag = utilities.NormalizeTag(tag); tag != "" {
+			names = append(names, tag)
+		}
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	args := append([]interface{}{videoID}, names...)
+	result, err := r.db.Exec(`
+		DELETE FROM video_tags
+		WHERE video_id = ? AND tag_id IN (
+			SELECT id FROM tags WHERE name IN (`+placeholders(len(names))+`)
+			UNION
+			SELECT tag_id FROM tag_aliases WHERE alias IN (`+placeholders(len(names))+`)
+		)`,
+		append(args, names...)...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления тегов: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления тегов: %v", err)
+	}
+	return int(n), nil
+}
+
+// tagID возвращает ID тега по имени или ErrTagNotFound
+func tagID(tx *sql.Tx, name string) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM tags WHERE name = ?", name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %s", ErrTagNotFound, name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка запроса тега: %v", err)
+	}
+	return id, nil
+}
+
+// existingTag ищет тег по имени или псевдониму и возвращает его ID и
+// каноничное имя или ErrTagNotFound
+func existingTag(tx *sql.Tx, name string) (int64, string, error) {
+	id, canonical, ok, err := lookupTag(tx, name)
+	if err != nil {
+		return 0, "", err
+	}
+	if !ok {
+		return 0, "", fmt.Errorf("%w: %s", ErrTagNotFound, utilities.NormalizeTag(name))
+	}
+	return id, canonical, nil
+}
+
+// saveAlias делает name псевдонимом тега tagID
+func (r *VideoRepository) saveAlias(tx *sql.Tx, name string, tagID int64) error {
+	_, err := tx.Exec(r.dialect.Upsert("tag_aliases", []string{"alias"}, []string{"alias", "tag_id", "translit_key"}),
+		name, tagID, utilities.TranslitKey(name))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения псевдонима: %v", err)
+	}
+	return nil
+}
+
+// deleteTag удаляет тег, связи с видео и псевдонимы удаляет ON DELETE CASCADE
+func deleteTag(tx *sql.Tx, id int64) error {
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", id); err != nil {
+		return fmt.Errorf("ошибка удаления тега: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetUpdateOffset возвращает последний update_id, до которого включительно
+// бот обработал все апдейты, или 0, если бот еще ничего не обрабатывал
+func (r *VideoRepository) GetUpdateOffset(botID int64) (int, error) {
+	var last int
+	err := r.db.QueryRow(
+		"SELECT last_update_id FROM update_offsets WHERE bot_id = ?",
+		botID,
+	).Scan(&last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения offset: %v", err)
+	}
+
+	return last, nil
+}
+
+// SaveUpdateOffset сохраняет offset и удаляет отметки об апдейтах до него:
+// Telegram их больше не пришлет
+func (r *VideoRepository) SaveUpdateOffset(botID int64, lastUpdateID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		r.dialect.Upsert("update_offsets", []string{"bot_id"}, []string{"bot_id", "last_update_id"}),
+		botID, lastUpdateID,
+	); err != nil {
+		return fmt.Errorf("ошибка сохранения offset: %v", err)
+	}
+
+	if _, err := tx.Exec(
+		"DELETE FROM processed_updates WHERE bot_id = ? AND update_id <= ?",
+		botID, lastUpdateID,
+	); err != nil {
+		return fmt.Errorf("ошибка очистки обработанных апдейтов: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// IsUpdateProcessed проверяет, был ли апдейт уже обработан
+func (r *VideoRepository) IsUpdateProcessed(botID int64, updateID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM processed_updates
+			WHERE bot_id = ? AND update_id = ?
+		)`, botID, updateID,
+	).Scan(&exists)
+
+	return exists, err
+}
+
+// MarkUpdateProcessed отмечает апдейт как обработанный
+func (r *VideoRepository) MarkUpdateProcessed(botID int64, updateID int) error {
+	_, err := r.db.Exec(
+		r.dialect.InsertIgnore+" INTO processed_updates (bot_id, update_id) VALUES (?, ?)",
+		botID, updateID,
+	)
+	return err
+}
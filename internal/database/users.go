@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tg-video-bot/internal/models"
+)
+
+// GetUserRole возвращает роль пользователя; без записи в users это viewer
+func (r *VideoRepository) GetUserRole(userID int64) (models.Role, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM users WHERE user_id = ?", userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RoleViewer, nil
+	}
+	if err != nil {
+		return models.RoleViewer, fmt.Errorf("ошибка получения роли: %v", err)
+	}
+
+	return models.Role(role), nil
+}
+
+// SetUserRole выдает пользователю роль
+func (r *VideoRepository) SetUserRole(userID int64, role models.Role, grantedBy int64) error {
+	_, err := r.db.Exec(
+		r.dialect.Upsert("users", []string{"user_id"}, []string{"user_id", "role", "granted_by"}),
+		userID, string(role), grantedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения роли: %v", err)
+	}
+	return nil
+}
+
+// DeleteUserRole убирает запись о роли, возвращая пользователя к viewer
+func (r *VideoRepository) DeleteUserRole(userID int64) error {
+	_, err := r.db.Exec("DELETE FROM users WHERE user_id = ?", userID)
+	return err
+}
+
+// ListUserRoles возвращает всех пользователей с выданными ролями
+func (r *VideoRepository) ListUserRoles() ([]models.UserRole, error) {
+	rows, err := r.db.Query(`
+		SELECT u.user_id, u.role, COALESCE(u.granted_by, 0)
+		FROM users u
+		JOIN roles r ON r.name = u.role
+		ORDER BY r.level DESC, u.user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса ролей: %v", err)
+	}
+	defer rows.Close()
+
+	var users []models.UserRole
+	for rows.Next() {
+		var u models.UserRole
+		var role string
+		if err := rows.Scan(&u.UserID, &role, &u.GrantedBy); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования роли: %v", err)
+		}
+		u.Role = models.Role(role)
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,10 @@
+package models
+
+// Album — файлы, присланные одним альбомом Telegram (с общим
+// media_group_id). Каждый файл хранится как отдельный Video с AlbumID,
+// подпись и теги у всех файлов альбома общие
+type Album struct {
+	ID      int64
+	Caption string
+	Videos  []Video
+}
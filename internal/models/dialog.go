@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ChatState — состояние многошагового диалога в чате. Диалог ведет
+// один пользователь, сообщения остальных участников чата его не двигают
+type ChatState struct {
+	ChatID int64
+	UserID int64
+	// State — имя текущего шага, например "add_tags:video"
+	State string
+	// Data — значения, собранные на предыдущих шагах
+	Data      map[string]string
+	ExpiresAt time.Time
+}
+
+// Expired проверяет, истек ли срок жизни состояния
+func (s ChatState) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,27 @@
+package models
+
+// MediaType — вид файла в коллекции. От него зависит метод отправки
+type MediaType string
+
+const (
+	MediaVideo     MediaType = "video"
+	MediaAnimation MediaType = "animation"
+	MediaPhoto     MediaType = "photo"
+	MediaVideoNote MediaType = "video_note"
+	MediaDocument  MediaType = "document"
+)
+
+// Label — название типа для сообщений бота
+func (t MediaType) Label() string {
+	switch t {
+	case MediaAnimation:
+		return "GIF"
+	case MediaPhoto:
+		return "Фото"
+	case MediaVideoNote:
+		return "Кружок"
+	case MediaDocument:
+		return "Документ"
+	}
+	return "Видео"
+}
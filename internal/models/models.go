@@ -1,13 +1,43 @@
 package models
 
+import "time"
+
+// Video — элемент коллекции. Кроме видео это может быть GIF, фото,
+// кружок или документ, см. Type
 type Video struct {
-	ID      int64
-	FileID  string
-	Caption string
-	Tags    []string
+	ID     int64
+	Type   MediaType // пустой тип означает MediaVideo
+	FileID string
+	// FileUniqueID одинаков для одного файла у всех ботов и не меняется
+	// со временем, в отличие от FileID. По нему ищутся дубликаты
+	FileUniqueID string
+	Caption      string
+	Tags         []string
+
+	// Метаданные файла, которые присылает Telegram
+	Duration    int // секунды
+	Width       int
+	Height      int
+	MimeType    string
+	FileSize    int64 // байты
+	ThumbFileID string
+
+	// AlbumID — альбом, в составе которого прислан файл, или 0
+	AlbumID int64
+
+	// UploaderID — Telegram ID пользователя, загрузившего видео
+	UploaderID int64
+	CreatedAt  time.Time
 }
 
 type Tag struct {
 	ID   int64
 	Name string
 }
+
+// TagAlias — другое имя тега: при сохранении и поиске Alias заменяется
+// на Tag
+type TagAlias struct {
+	Alias string
+	Tag   string
+}
@@ -0,0 +1,49 @@
+package models
+
+// Role — роль пользователя. Роли упорядочены: каждая следующая
+// включает права предыдущих
+type Role string
+
+const (
+	RoleBanned    Role = "banned"
+	RoleViewer    Role = "viewer"
+	RoleUploader  Role = "uploader"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+	RoleOwner     Role = "owner"
+)
+
+// Roles перечисляет роли по возрастанию прав
+var Roles = []Role{RoleBanned, RoleViewer, RoleUploader, RoleModerator, RoleAdmin, RoleOwner}
+
+// Level возвращает уровень роли; неизвестная роль приравнивается к viewer
+func (r Role) Level() int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+	return RoleViewer.Level()
+}
+
+// AtLeast проверяет, что роль не ниже required
+func (r Role) AtLeast(required Role) bool {
+	return r.Level() >= required.Level()
+}
+
+// ParseRole разбирает имя роли
+func ParseRole(name string) (Role, bool) {
+	for _, role := range Roles {
+		if string(role) == name {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// UserRole — роль, выданная пользователю
+type UserRole struct {
+	UserID    int64
+	Role      Role
+	GrantedBy int64
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Источники отправки видео для статистики
+const (
+	SourceRandom = "random"
+	SourceTag    = "tag"
+	SourceButton = "button"
+	SourceSearch = "search"
+	SourceInline = "inline"
+)
+
+// Stats — сводка использования бота за период начиная с Since
+type Stats struct {
+	Since time.Time
+
+	// Состояние библиотеки и аудитории за все время
+	TotalVideos int
+	TotalTags   int
+	TotalChats  int
+
+	// Отправки за период
+	Sent        int
+	ActiveChats int
+	PerDay      []DayCount
+
+	TopVideos   []VideoCount
+	TopTags     []TagCount
+	TopRequests []TagCount
+	TopChats    []ChatCount
+}
+
+// DayCount — число отправок за день в формате YYYY-MM-DD
+type DayCount struct {
+	Day   string
+	Count int
+}
+
+// VideoCount — число отправок видео
+type VideoCount struct {
+	VideoID int64
+	Caption string
+	Count   int
+}
+
+// TagCount — число отправок или запросов по тегу
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// ChatCount — потребление видео чатом: отправки за период и
+// сколько разных видео чат получил за все время
+type ChatCount struct {
+	ChatID int64
+	Sent   int
+	Seen   int
+}
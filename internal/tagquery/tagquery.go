@@ -0,0 +1,340 @@
+// Package tagquery разбирает поисковые запросы по тегам вида
+//
+//	котики -собаки (смешные | мемы) муз*
+//
+// Слова через пробел объединяются по И, "|" или OR — по ИЛИ, "-" или NOT
+// перед словом или скобкой исключает совпадения, "*" в слове заменяет
+// любую последовательность символов. NOT связывает сильнее И, И сильнее ИЛИ
+package tagquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tg-video-bot/pkg/utilities"
+	"unicode"
+)
+
+// Expr — узел разобранного запроса
+type Expr interface {
+	// Match проверяет набор тегов видео
+	Match(tags []string) bool
+	String() string
+}
+
+// Term — один тег. Если в нем есть "*", это шаблон
+type Term struct {
+	Tag string
+}
+
+// Not исключает видео, подходящие под X
+type Not struct {
+	X Expr
+}
+
+// And требует совпадения всех условий
+type And []Expr
+
+// Or требует совпадения хотя бы одного условия
+type Or []Expr
+
+// IsPattern сообщает, что тег содержит "*"
+func (t Term) IsPattern() bool {
+	return strings.Contains(t.Tag, "*")
+}
+
+func (t Term) Match(tags []string) bool {
+	for _, tag := range tags {
+		if t.matchTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTag сравнивает тег с шаблоном: части между "*" должны идти по порядку
+func (t Term) matchTag(tag string) bool {
+	if !t.IsPattern() {
+		return tag == t.Tag
+	}
+
+	parts := strings.Split(t.Tag, "*")
+	if !strings.HasPrefix(tag, parts[0]) {
+		return false
+	}
+	tag = tag[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(tag, part)
+		if i < 0 {
+			return false
+		}
+		tag = tag[i+len(part):]
+	}
+	return len(tag) >= len(last) && strings.HasSuffix(tag, last)
+}
+
+func (t Term) String() string { return t.Tag }
+
+func (n Not) Match(tags []string) bool { return !n.X.Match(tags) }
+
+func (n Not) String() string { return "-" + n.X.String() }
+
+func (a And) Match(tags []string) bool {
+	for _, x := range a {
+		if !x.Match(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a And) String() string { return join(a, " ") }
+
+func (o Or) Match(tags []string) bool {
+	for _, x := range o {
+		if x.Match(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Or) String() string { return "(" + join(o, " | ") + ")" }
+
+func join(exprs []Expr, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, x := range exprs {
+		parts[i] = x.String()
+	}
+	return strings.Join(parts, sep)
+}
+
+// Tags возвращает теги, которые запрос требует, а не исключает, без шаблонов.
+// Используется для статистики запросов
+func Tags(e Expr) []string {
+	var tags []string
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch x := e.(type) {
+		case Term:
+			if !x.IsPattern() {
+				tags = append(tags, x.Tag)
+			}
+		case And:
+			for _, y := range x {
+				walk(y)
+			}
+		case Or:
+			for _, y := range x {
+				walk(y)
+			}
+		}
+	}
+	walk(e)
+	return tags
+}
+
+// MapTags возвращает копию запроса, в которой теги без "*" заменены на
+// f(тег). Используется, чтобы подставить каноничные имена вместо псевдонимов
+func MapTags(e Expr, f func(string) string) Expr {
+	switch x := e.(type) {
+	case Term:
+		if !x.IsPattern() {
+			x.Tag = f(x.Tag)
+		}
+		return x
+	case Not:
+		return Not{X: MapTags(x.X, f)}
+	case And:
+		mapped := make(And, len(x))
+		for i, y := range x {
+			mapped[i] = MapTags(y, f)
+		}
+		return mapped
+	case Or:
+		mapped := make(Or, len(x))
+		for i, y := range x {
+			mapped[i] = MapTags(y, f)
+		}
+		return mapped
+	}
+	return e
+}
+
+// Ошибки разбора
+var (
+	ErrEmpty      = errors.New("пустой запрос")
+	ErrUnbalanced = errors.New("непарная скобка")
+)
+
+// Parse разбирает запрос
+func Parse(query string) (Expr, error) {
+	p := &parser{tokens: tokenize(query)}
+	if len(p.tokens) == 0 {
+		return nil, ErrEmpty
+	}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		if tok == ")" {
+			return nil, ErrUnbalanced
+		}
+		return nil, fmt.Errorf("лишнее %q в запросе", tok)
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() string {
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok
+}
+
+// parseOr: and { ("|" | OR) and }
+func (p *parser) parseOr() (Expr, error) {
+	var or Or
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, e)
+
+		tok, ok := p.peek()
+		if !ok || (tok != "|" && tok != "OR") {
+			break
+		}
+		p.next()
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+// parseAnd: unary { [AND] unary }. Соседние слова объединяются по И
+func (p *parser) parseAnd() (Expr, error) {
+	var and And
+	for {
+		tok, ok := p.peek()
+		if !ok || tok == "|" || tok == "OR" || tok == ")" {
+			break
+		}
+		if tok == "AND" {
+			if len(and) == 0 {
+				return nil, fmt.Errorf("AND без условия слева")
+			}
+			p.next()
+			if tok, ok := p.peek(); !ok || tok == "|" || tok == "OR" || tok == ")" || tok == "AND" {
+				return nil, fmt.Errorf("AND без условия справа")
+			}
+			continue
+		}
+
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, e)
+	}
+
+	switch len(and) {
+	case 0:
+		if tok, ok := p.peek(); ok {
+			return nil, fmt.Errorf("нет условия перед %q", tok)
+		}
+		return nil, fmt.Errorf("запрос обрывается на операторе")
+	case 1:
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// parseUnary: ("-" | NOT) unary | "(" or ")" | тег
+func (p *parser) parseUnary() (Expr, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("запрос обрывается на операторе")
+	}
+
+	switch tok {
+	case "-", "NOT":
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{X: e}, nil
+
+	case "(":
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.peek(); !ok || tok != ")" {
+			return nil, ErrUnbalanced
+		}
+		p.next()
+		return e, nil
+
+	case ")":
+		return nil, ErrUnbalanced
+	}
+
+	p.next()
+	tag := utilities.NormalizeTag(tok)
+	if strings.Trim(tag, "*") == "" && tag != "*" {
+		return nil, fmt.Errorf("пустой тег")
+	}
+	return Term{Tag: tag}, nil
+}
+
+// tokenize делит запрос на скобки, "|", "-" перед словом или скобкой
+// и слова. Ключевые слова AND, OR и NOT распознаются только заглавными
+func tokenize(query string) []string {
+	var tokens []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')' || r == '|':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == '-' && word.Len() == 0:
+			tokens = append(tokens, "-")
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
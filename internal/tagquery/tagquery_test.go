@@ -0,0 +1,154 @@
+package tagquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"котики", "котики"},
+		{"#Котики Ёжики", "котики ежики"},
+		{"котики собаки", "котики собаки"},
+		{"котики AND собаки", "котики собаки"},
+		{"котики | собаки", "(котики | собаки)"},
+		{"котики OR собаки", "(котики | собаки)"},
+		// NOT связывает сильнее И, И сильнее ИЛИ
+		{"a b | c", "(a b | c)"},
+		{"a | b c", "(a | b c)"},
+		{"-a b", "-a b"},
+		{"NOT a AND b OR c", "(-a b | c)"},
+		{"a (b | c)", "a (b | c)"},
+		{"-(a | b) c", "-(a | b) c"},
+		{"((a))", "a"},
+		// Запрос только из исключения
+		{"-a", "-a"},
+		{"NOT a", "-a"},
+		{"--a", "--a"},
+		// Ключевые слова распознаются только заглавными, "-" — только в начале слова
+		{"and or not", "and or not"},
+		{"a-b", "a-b"},
+		{"муз*", "муз*"},
+		{"*", "*"},
+		// Спецсимволы LIKE в тегах остаются как есть
+		{"a_b 50% c!d", "a_b 50% c!d"},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.query, err)
+			continue
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		// want — ожидаемая ошибка; nil — подходит любая
+		want error
+	}{
+		{"", ErrEmpty},
+		{"   ", ErrEmpty},
+		{"(a | b", ErrUnbalanced},
+		{"a)", ErrUnbalanced},
+		{"(a b))", ErrUnbalanced},
+		{"-(a", ErrUnbalanced},
+		{")", nil},
+		{"()", nil},
+		{"a |", nil},
+		{"| a", nil},
+		{"a | | b", nil},
+		{"AND a", nil},
+		{"a AND", nil},
+		{"a AND OR b", nil},
+		{"-", nil},
+		{"a -", nil},
+		{"NOT", nil},
+		{"#", nil},
+		{"**", nil},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.query)
+		if err == nil {
+			t.Errorf("Parse(%q) = %v, want error", tt.query, e)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		tags  []string
+		want  bool
+	}{
+		{"котики", []string{"котики"}, true},
+		{"котики", []string{"собаки"}, false},
+		{"котики", nil, false},
+		{"-собаки", nil, true},
+		{"-собаки", []string{"собаки"}, false},
+		{"a -b", []string{"a"}, true},
+		{"a -b", []string{"a", "b"}, false},
+		{"a | b c", []string{"a"}, true},
+		{"a | b c", []string{"b"}, false},
+		{"a | b c", []string{"c", "b"}, true},
+		{"-(a | b)", []string{"c"}, true},
+		{"-(a | b)", []string{"b"}, false},
+		{"-a | b", []string{"a", "b"}, true},
+		{"-a | b", []string{"a"}, false},
+		// Шаблоны
+		{"муз*", []string{"музыка"}, true},
+		{"муз*", []string{"муз"}, true},
+		{"муз*", []string{"ремуз"}, false},
+		{"*ка", []string{"музыка"}, true},
+		{"a*b*c", []string{"abc"}, true},
+		{"a*b*c", []string{"axxbyyc"}, true},
+		{"a*b*c", []string{"acb"}, false},
+		{"ab*b", []string{"ab"}, false},
+		{"ab*b", []string{"abb"}, true},
+		{"*", []string{"что угодно"}, true},
+		{"*", nil, false},
+		// "_", "%" и "!" — обычные символы, а не шаблоны
+		{"a_b", []string{"axb"}, false},
+		{"a_b", []string{"a_b"}, true},
+		{"50%", []string{"500"}, false},
+		{"50%", []string{"50%"}, true},
+		{"c!d", []string{"c!d"}, true},
+		{"a_*", []string{"axb"}, false},
+		{"a_*", []string{"a_b"}, true},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.query, err)
+		}
+		if got := e.Match(tt.tags); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.query, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	e, err := Parse("a -b (c | d*) NOT (e f)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Tags(e)
+	want := []string{"a", "c"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Tags() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,615 @@
+// Package tgtest поднимает локальный поддельный Telegram Bot API для
+// сквозных тестов бота: сервер отдает заранее подготовленные апдейты через
+// getUpdates и запоминает все исходящие вызовы (sendMessage, sendVideo,
+// answerCallbackQuery и т.д.), чтобы тест мог проверить ответы бота.
+package tgtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Token — токен, который ожидает сервер по умолчанию
+const Token = "123456:TEST"
+
+// maxPollWait ограничивает long polling, чтобы тесты не ждали по минуте
+const maxPollWait = time.Second
+
+// Request — запомненный вызов метода Bot API
+type Request struct {
+	Method string
+	Params url.Values
+}
+
+// ChatID возвращает chat_id запроса
+func (r Request) ChatID() int64 {
+	id, _ := strconv.ParseInt(r.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+// pendingUpdate — апдейт в очереди getUpdates. Хранится в JSON, чтобы
+// отдавать и поля, которых нет в tgbotapi v4
+type pendingUpdate struct {
+	id   int
+	data json.RawMessage
+}
+
+// Server — поддельный Bot API
+type Server struct {
+	*httptest.Server
+
+	Self tgbotapi.User
+
+	mu           sync.Mutex
+	cond         *sync.Cond
+	closed       bool
+	nextUpdateID int
+	nextMsgID    int
+	updates      []pendingUpdate
+	requests     []Request
+	files        map[string]string
+	webhookURL   string
+	failures     map[string][]int
+}
+
+// NewServer запускает сервер на случайном локальном порту
+func NewServer() *Server {
+	s := &Server{
+		Self:         tgbotapi.User{ID: 1, FirstName: "Test", UserName: "test_bot", IsBot: true},
+		nextUpdateID: 1,
+		nextMsgID:    1,
+		files:        make(map[string]string),
+		failures:     make(map[string][]int),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close останавливает сервер и будит ожидающие getUpdates
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.Server.Close()
+}
+
+// Client возвращает http.Client, перенаправляющий запросы к api.telegram.org
+// на этот сервер. Его нужно передать в tgbotapi.NewBotAPIWithClient
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Transport: &rewriteTransport{target: target}}
+}
+
+// NewBotAPI создает клиент tgbotapi, подключенный к серверу
+func (s *Server) NewBotAPI() (*tgbotapi.BotAPI, error) {
+	return tgbotapi.NewBotAPIWithClient(Token, s.Client())
+}
+
+// PushUpdate ставит апдейт в очередь getUpdates и возвращает присвоенный update_id
+func (s *Server) PushUpdate(update tgbotapi.Update) int {
+	return s.PushUpdateExtra(update, nil)
+}
+
+// PushUpdateExtra ставит апдейт в очередь, добавив в его сообщение поля
+// messageExtra, которых нет в tgbotapi v4 (caption_entities и т.п.)
+func (s *Server) PushUpdateExtra(update tgbotapi.Update, messageExtra map[string]interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update.UpdateID = s.nextUpdateID
+	s.nextUpdateID++
+	s.updates = append(s.updates, pendingUpdate{id: update.UpdateID, data: encodeUpdate(update, messageExtra)})
+	s.cond.Broadcast()
+
+	return update.UpdateID
+}
+
+func encodeUpdate(update tgbotapi.Update, messageExtra map[string]interface{}) json.RawMessage {
+	data, err := json.Marshal(update)
+	if err != nil || len(messageExtra) == 0 {
+		return data
+	}
+
+	var fields map[string]json.RawMessage
+	var message map[string]interface{}
+	if json.Unmarshal(data, &fields) != nil || json.Unmarshal(fields["message"], &message) != nil {
+		return data
+	}
+	for k, v := range messageExtra {
+		message[k] = v
+	}
+	fields["message"], _ = json.Marshal(message)
+
+	data, _ = json.Marshal(fields)
+	return data
+}
+
+// PushMessage ставит в очередь сообщение от пользователя fromID в чат chatID.
+// Команды (текст, начинающийся с "/") получают сущность bot_command
+func (s *Server) PushMessage(chatID int64, fromID int, text string) int {
+	msg := s.newMessage(chatID, fromID)
+	msg.Text = text
+	if strings.HasPrefix(text, "/") {
+		length := len([]rune(strings.Fields(text)[0]))
+		msg.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	return s.PushUpdate(tgbotapi.Update{Message: msg})
+}
+
+// PushVideo ставит в очередь видео с подписью от пользователя fromID.
+// Хештеги подписи размечаются в caption_entities, как это делает Telegram.
+// file_unique_id выводится из fileID
+func (s *Server) PushVideo(chatID int64, fromID int, fileID, caption string) int {
+	return s.PushVideoFile(chatID, fromID, fileID, "u-"+fileID, caption)
+}
+
+// PushVideoFile — как PushVideo, но с явным file_unique_id, чтобы
+// имитировать один и тот же файл под разными file_id
+func (s *Server) PushVideoFile(chatID int64, fromID int, fileID, fileUniqueID, caption string) int {
+	msg := s.newMessage(chatID, fromID)
+	msg.Video = &tgbotapi.Video{
+		FileID: fileID, Width: 640, Height: 360, Duration: 10, MimeType: "video/mp4", FileSize: 1 << 20,
+		Thumbnail: &tgbotapi.PhotoSize{FileID: fileID + "_thumb", Width: 320, Height: 180},
+	}
+	msg.Caption = caption
+
+	video := map[string]interface{}{}
+	data, _ := json.Marshal(msg.Video)
+	json.Unmarshal(data, &video)
+	video["file_unique_id"] = fileUniqueID
+
+	extra := map[string]interface{}{"video": video}
+	if entities := hashtagEntities(caption); len(entities) > 0 {
+		extra["caption_entities"] = entities
+	}
+	return s.PushUpdateExtra(tgbotapi.Update{Message: msg}, extra)
+}
+
+// PushMedia ставит в очередь файл вида kind ("animation", "photo",
+// "video_note" или "document") с подписью от пользователя fromID.
+// Для animation, как и Telegram, дополнительно заполняется document
+func (s *Server) PushMedia(chatID int64, fromID int, kind, fileID, caption string) int {
+	msg := s.newMessage(chatID, fromID)
+	msg.Caption = caption
+	thumb := &tgbotapi.PhotoSize{FileID: fileID + "_thumb", Width: 320, Height: 180}
+
+	var file interface{}
+	switch kind {
+	case "animation":
+		msg.Animation = &tgbotapi.ChatAnimation{
+			FileID: fileID, Width: 480, Height: 270, Duration: 3, MimeType: "video/mp4", FileSize: 1 << 18, Thumbnail: thumb,
+		}
+		msg.Document = &tgbotapi.Document{FileID: fileID, MimeType: "video/mp4", FileSize: 1 << 18, Thumbnail: thumb}
+		file = msg.Animation
+	case "photo":
+		msg.Photo = &[]tgbotapi.PhotoSize{
+			{FileID: fileID + "_s", Width: 90, Height: 90, FileSize: 1 << 10},
+			{FileID: fileID, Width: 1280, Height: 1280, FileSize: 1 << 17},
+		}
+	case "video_note":
+		msg.VideoNote = &tgbotapi.VideoNote{FileID: fileID, Length: 240, Duration: 5, FileSize: 1 << 18, Thumbnail: thumb}
+		file = msg.VideoNote
+	case "document":
+		msg.Document = &tgbotapi.Document{FileID: fileID, FileName: fileID + ".mp4", MimeType: "video/mp4", FileSize: 1 << 20}
+		file = msg.Document
+	}
+
+	extra := map[string]interface{}{}
+	if kind == "photo" {
+		extra["photo"] = []map[string]interface{}{
+			{"file_id": fileID + "_s", "file_unique_id": "u-" + fileID + "_s", "width": 90, "height": 90, "file_size": 1 << 10},
+			{"file_id": fileID, "file_unique_id": "u-" + fileID, "width": 1280, "height": 1280, "file_size": 1 << 17},
+		}
+	} else {
+		fields := map[string]interface{}{}
+		data, _ := json.Marshal(file)
+		json.Unmarshal(data, &fields)
+		fields["file_unique_id"] = "u-" + fileID
+		extra[kind] = fields
+	}
+	if entities := hashtagEntities(caption); len(entities) > 0 {
+		extra["caption_entities"] = entities
+	}
+	return s.PushUpdateExtra(tgbotapi.Update{Message: msg}, extra)
+}
+
+// PushAlbum ставит в очередь альбом видео: по сообщению на файл с общим
+// media_group_id. Подпись, как и в Telegram, есть только у первого файла
+func (s *Server) PushAlbum(chatID int64, fromID int, groupID string, fileIDs []string, caption string) {
+	for i, fileID := range fileIDs {
+		msg := s.newMessage(chatID, fromID)
+		msg.Video = &tgbotapi.Video{FileID: fileID, Width: 640, Height: 360, Duration: 10, MimeType: "video/mp4", FileSize: 1 << 20}
+
+		video := map[string]interface{}{}
+		data, _ := json.Marshal(msg.Video)
+		json.Unmarshal(data, &video)
+		video["file_unique_id"] = "u-" + fileID
+
+		extra := map[string]interface{}{"video": video, "media_group_id": groupID}
+		if i == 0 {
+			msg.Caption = caption
+			if entities := hashtagEntities(caption); len(entities) > 0 {
+				extra["caption_entities"] = entities
+			}
+		}
+		s.PushUpdateExtra(tgbotapi.Update{Message: msg}, extra)
+	}
+}
+
+// hashtagEntities размечает слова, начинающиеся с "#" и содержащие хотя бы
+// одну букву. Смещения считаются в единицах UTF-16
+func hashtagEntities(text string) []tgbotapi.MessageEntity {
+	var entities []tgbotapi.MessageEntity
+	offset := 0
+	start, hasLetter := -1, false
+
+	flush := func() {
+		if start >= 0 && hasLetter && offset-start > 1 {
+			entities = append(entities, tgbotapi.MessageEntity{Type: "hashtag", Offset: start, Length: offset - start})
+		}
+		start, hasLetter = -1, false
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '#' && start < 0:
+			start = offset
+		case start >= 0 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			hasLetter = hasLetter || unicode.IsLetter(r)
+		default:
+			flush()
+			if r == '#' {
+				start = offset
+			}
+		}
+		offset += len(utf16.Encode([]rune{r}))
+	}
+	flush()
+
+	return entities
+}
+
+// PushCallback ставит в очередь нажатие инлайн-кнопки с данными data
+func (s *Server) PushCallback(chatID int64, fromID int, data string) int {
+	msg := s.newMessage(chatID, s.Self.ID)
+	query := &tgbotapi.CallbackQuery{
+		ID:      fmt.Sprintf("cb%d", msg.MessageID),
+		From:    &tgbotapi.User{ID: fromID, FirstName: "User"},
+		Message: msg,
+		Data:    data,
+	}
+	return s.PushUpdate(tgbotapi.Update{CallbackQuery: query})
+}
+
+// PushInlineQuery добавляет инлайн-запрос пользователя fromID
+func (s *Server) PushInlineQuery(fromID int, query, offset string) int {
+	s.mu.Lock()
+	id := fmt.Sprintf("iq%d", s.nextMsgID)
+	s.nextMsgID++
+	s.mu.Unlock()
+	return s.PushUpdate(tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{
+		ID:     id,
+		From:   &tgbotapi.User{ID: fromID, FirstName: "User"},
+		Query:  query,
+		Offset: offset,
+	}})
+}
+
+// PushChosenInlineResult добавляет выбор результата инлайн-запроса
+func (s *Server) PushChosenInlineResult(fromID int, resultID, query string) int {
+	return s.PushUpdate(tgbotapi.Update{ChosenInlineResult: &tgbotapi.ChosenInlineResult{
+		ResultID: resultID,
+		From:     &tgbotapi.User{ID: fromID, FirstName: "User"},
+		Query:    query,
+	}})
+}
+
+// AddFile регистрирует файл, который вернет getFile
+func (s *Server) AddFile(fileID, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fileID] = path
+}
+
+// FailNext заставляет следующий вызов method ответить 429 Too Many Requests
+// с указанным retry_after. Вызовы накапливаются
+func (s *Server) FailNext(method string, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], retryAfter)
+}
+
+// Requests возвращает запомненные вызовы указанных методов (или все, если методы не заданы)
+func (s *Server) Requests(methods ...string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filter(methods)
+}
+
+// WaitRequests ждет, пока накопится не меньше n вызовов указанного метода
+func (s *Server) WaitRequests(method string, n int, timeout time.Duration) ([]Request, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		reqs := s.Requests(method)
+		if len(reqs) >= n {
+			return reqs, nil
+		}
+		if time.Now().After(deadline) {
+			return reqs, fmt.Errorf("tgtest: ждали %d вызовов %s, получили %d", n, method, len(reqs))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reset забывает запомненные вызовы
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) newMessage(chatID int64, fromID int) *tgbotapi.Message {
+	s.mu.Lock()
+	id := s.nextMsgID
+	s.nextMsgID++
+	s.mu.Unlock()
+
+	chatType := "private"
+	if chatID < 0 {
+		chatType = "supergroup"
+	}
+
+	return &tgbotapi.Message{
+		MessageID: id,
+		From:      &tgbotapi.User{ID: fromID, FirstName: "User"},
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: chatType},
+	}
+}
+
+func (s *Server) filter(methods []string) []Request {
+	var out []Request
+	for _, r := range s.requests {
+		if len(methods) == 0 {
+			out = append(out, r)
+			continue
+		}
+		for _, m := range methods {
+			if r.Method == m {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// Путь имеет вид /bot<token>/<method>
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] != "bot"+Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	method := parts[1]
+
+	params, err := parseParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, s.Self)
+	case "getUpdates":
+		writeResult(w, s.pollUpdates(params))
+	case "getFile":
+		s.serveGetFile(w, params)
+	case "getWebhookInfo":
+		s.mu.Lock()
+		info := tgbotapi.WebhookInfo{URL: s.webhookURL}
+		s.mu.Unlock()
+		writeResult(w, info)
+	default:
+		s.mu.Lock()
+		if pending := s.failures[method]; len(pending) > 0 {
+			s.failures[method] = pending[1:]
+			s.mu.Unlock()
+			writeTooManyRequests(w, pending[0])
+			return
+		}
+		s.requests = append(s.requests, Request{Method: method, Params: params})
+		switch method {
+		case "setWebhook":
+			s.webhookURL = params.Get("url")
+		case "deleteWebhook":
+			s.webhookURL = ""
+		}
+		s.mu.Unlock()
+
+		if method == "sendMediaGroup" {
+			writeResult(w, s.sentMediaGroup(params))
+			return
+		}
+		if strings.HasPrefix(method, "send") {
+			writeResult(w, s.sentMessage(method, params))
+			return
+		}
+		// Редактирование обычного сообщения возвращает его, inline-сообщения — true
+		if strings.HasPrefix(method, "editMessage") && params.Get("chat_id") != "" {
+			msg := s.sentMessage(method, params)
+			msg.MessageID, _ = strconv.Atoi(params.Get("message_id"))
+			writeResult(w, msg)
+			return
+		}
+		writeResult(w, true)
+	}
+}
+
+func (s *Server) pollUpdates(params url.Values) []json.RawMessage {
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	timeout, _ := strconv.Atoi(params.Get("timeout"))
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxPollWait {
+		wait = maxPollWait
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Подтвержденные апдейты больше не нужны
+	pending := s.updates[:0]
+	for _, u := range s.updates {
+		if u.id >= offset {
+			pending = append(pending, u)
+		}
+	}
+	s.updates = pending
+
+	if len(s.updates) == 0 && wait > 0 && !s.closed {
+		timer := time.AfterFunc(wait, func() {
+			s.mu.Lock()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+		})
+		s.cond.Wait()
+		timer.Stop()
+	}
+
+	out := make([]json.RawMessage, len(s.updates))
+	for i, u := range s.updates {
+		out[i] = u.data
+	}
+	return out
+}
+
+func (s *Server) serveGetFile(w http.ResponseWriter, params url.Values) {
+	fileID := params.Get("file_id")
+
+	s.mu.Lock()
+	path, ok := s.files[fileID]
+	s.requests = append(s.requests, Request{Method: "getFile", Params: params})
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid file_id")
+		return
+	}
+	writeResult(w, tgbotapi.File{FileID: fileID, FilePath: path})
+}
+
+// sentMessage строит ответ на send*-метод так, как его вернул бы Telegram
+func (s *Server) sentMessage(method string, params url.Values) *tgbotapi.Message {
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	msg := s.newMessage(chatID, s.Self.ID)
+	msg.From.IsBot = true
+	msg.Text = params.Get("text")
+	msg.Caption = params.Get("caption")
+
+	switch method {
+	case "sendVideo":
+		msg.Video = &tgbotapi.Video{FileID: params.Get("video")}
+	case "sendAnimation":
+		msg.Animation = &tgbotapi.ChatAnimation{FileID: params.Get("animation")}
+	case "sendDocument":
+		msg.Document = &tgbotapi.Document{FileID: params.Get("document")}
+	case "sendVideoNote":
+		msg.VideoNote = &tgbotapi.VideoNote{FileID: params.Get("video_note")}
+	case "sendPhoto":
+		msg.Photo = &[]tgbotapi.PhotoSize{{FileID: params.Get("photo")}}
+	}
+
+	return msg
+}
+
+// sentMediaGroup строит ответ на sendMediaGroup: массив сообщений,
+// по одному на каждый элемент альбома
+func (s *Server) sentMediaGroup(params url.Values) []*tgbotapi.Message {
+	var media []struct {
+		Type    string `json:"type"`
+		Media   string `json:"media"`
+		Caption string `json:"caption"`
+	}
+	json.Unmarshal([]byte(params.Get("media")), &media)
+
+	messages := make([]*tgbotapi.Message, len(media))
+	for i, m := range media {
+		item := url.Values{}
+		item.Set("chat_id", params.Get("chat_id"))
+		item.Set("caption", m.Caption)
+		item.Set(m.Type, m.Media)
+		messages[i] = s.sentMessage("send"+strings.ToUpper(m.Type[:1])+m.Type[1:], item)
+	}
+	return messages
+}
+
+func parseParams(r *http.Request) (url.Values, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		params := url.Values{}
+		for k, v := range r.MultipartForm.Value {
+			params[k] = v
+		}
+		for k := range r.MultipartForm.File {
+			params.Set(k, "<upload>")
+		}
+		return params, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return r.Form, nil
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	data, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: data})
+}
+
+func writeError(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, ErrorCode: code, Description: description})
+}
+
+func writeTooManyRequests(w http.ResponseWriter, retryAfter int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{
+		Ok:          false,
+		ErrorCode:   http.StatusTooManyRequests,
+		Description: fmt.Sprintf("Too Many Requests: retry after %d", retryAfter),
+		Parameters:  &tgbotapi.ResponseParameters{RetryAfter: retryAfter},
+	})
+}
+
+// rewriteTransport направляет все запросы на адрес тестового сервера
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
@@ -0,0 +1,140 @@
+package tgtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func newTestAPI(t *testing.T) (*Server, *tgbotapi.BotAPI) {
+	t.Helper()
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	api, err := srv.NewBotAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, api
+}
+
+func TestGetUpdatesOffset(t *testing.T) {
+	srv, api := newTestAPI(t)
+
+	first := srv.PushMessage(5, 10, "/start")
+	second := srv.PushMessage(5, 10, "привет")
+
+	updates, err := api.GetUpdates(tgbotapi.NewUpdate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 2 || updates[0].UpdateID != first || updates[1].UpdateID != second {
+		t.Fatalf("got %d updates, want %d and %d", len(updates), first, second)
+	}
+	if !updates[0].Message.IsCommand() || updates[0].Message.Command() != "start" {
+		t.Fatalf("first update is not /start: %+v", updates[0].Message)
+	}
+
+	// Запрос со следующим offset подтверждает полученные апдейты
+	updates, err = api.GetUpdates(tgbotapi.NewUpdate(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].UpdateID != second {
+		t.Fatalf("after offset %d got %d updates, want only %d", second, len(updates), second)
+	}
+
+	// Long poll просыпается, когда приходит новый апдейт
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		srv.PushCallback(5, 10, "data")
+	}()
+	config := tgbotapi.NewUpdate(second + 1)
+	config.Timeout = 5
+	updates, err = api.GetUpdates(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].CallbackQuery == nil || updates[0].CallbackQuery.Data != "data" {
+		t.Fatalf("long poll returned %+v, want the callback", updates)
+	}
+}
+
+func TestRecordsRequests(t *testing.T) {
+	srv, api := newTestAPI(t)
+
+	msg, err := api.Send(tgbotapi.NewMessage(5, "привет"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Chat == nil || msg.Chat.ID != 5 || msg.Text != "привет" || msg.MessageID == 0 {
+		t.Fatalf("sendMessage returned %+v", msg)
+	}
+	if _, err := api.Send(tgbotapi.NewVideoShare(6, "vid1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.AnswerCallbackQuery(tgbotapi.NewCallback("cb1", "готово")); err != nil {
+		t.Fatal(err)
+	}
+
+	if reqs := srv.Requests("sendMessage"); len(reqs) != 1 || reqs[0].ChatID() != 5 || reqs[0].Params.Get("text") != "привет" {
+		t.Fatalf("sendMessage requests = %v", reqs)
+	}
+	if reqs := srv.Requests("sendVideo"); len(reqs) != 1 || reqs[0].ChatID() != 6 || reqs[0].Params.Get("video") != "vid1" {
+		t.Fatalf("sendVideo requests = %v", reqs)
+	}
+	reqs := srv.Requests("answerCallbackQuery")
+	if len(reqs) != 1 || reqs[0].Params.Get("callback_query_id") != "cb1" || reqs[0].Params.Get("text") != "готово" {
+		t.Fatalf("answerCallbackQuery requests = %v", reqs)
+	}
+	if all := srv.Requests(); len(all) != 3 {
+		t.Fatalf("got %d requests in total, want 3", len(all))
+	}
+
+	srv.Reset()
+	if all := srv.Requests(); len(all) != 0 {
+		t.Fatalf("got %d requests after Reset, want 0", len(all))
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	srv, api := newTestAPI(t)
+	srv.AddFile("vid1", "videos/file_1.mp4")
+
+	file, err := api.GetFile(tgbotapi.FileConfig{FileID: "vid1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FilePath != "videos/file_1.mp4" {
+		t.Fatalf("file_path = %q, want videos/file_1.mp4", file.FilePath)
+	}
+
+	if _, err := api.GetFile(tgbotapi.FileConfig{FileID: "missing"}); err == nil {
+		t.Fatal("getFile of an unknown file succeeded")
+	}
+	if reqs := srv.Requests("getFile"); len(reqs) != 2 || reqs[0].Params.Get("file_id") != "vid1" {
+		t.Fatalf("getFile requests = %v", reqs)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	srv, api := newTestAPI(t)
+	srv.FailNext("sendMessage", 3)
+
+	_, err := api.Send(tgbotapi.NewMessage(5, "a"))
+	var apiErr tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 3 {
+		t.Fatalf("first sendMessage error = %v, want 429 with retry_after 3", err)
+	}
+	if reqs := srv.Requests("sendMessage"); len(reqs) != 0 {
+		t.Fatalf("failed call was recorded: %v", reqs)
+	}
+
+	if _, err := api.Send(tgbotapi.NewMessage(5, "b")); err != nil {
+		t.Fatalf("second sendMessage: %v", err)
+	}
+	if reqs := srv.Requests("sendMessage"); len(reqs) != 1 || reqs[0].Params.Get("text") != "b" {
+		t.Fatalf("sendMessage requests = %v", reqs)
+	}
+}
@@ -1,7 +1,55 @@
 package utilities
 
-import "strings"
+import (
+	"strings"
 
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeTag приводит тег к виду, в котором он хранится: NFKC, нижний
+// регистр, ё → е, без ведущих # и пробелов по краям. NFKC сводит
+// полноширинные и составные символы к обычным, поэтому «ＣＡＴＳ» и «cats»
+// становятся одним тегом
 func NormalizeTag(tag string) string {
-	return strings.TrimSpace(strings.ToLower(tag))
+	tag = strings.ToLower(norm.NFKC.String(tag))
+	tag = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(tag), "#"))
+	return strings.ReplaceAll(tag, "ё", "е")
+}
+
+// translit — латинская запись русских букв. Й и ы записываются как i,
+// чтобы совпадать с английскими вариантами вроде kotiki и kotyki после
+// translitFolds
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "h", 'ц': "c", 'ч': "ch", 'ш': "sh", 'щ': "sch",
+	'ъ': "", 'ы': "i", 'ь': "", 'э': "e", 'ю': "iu", 'я': "ia",
+}
+
+// translitFolds сводит разные латинские записи одних звуков к одной
+var translitFolds = strings.NewReplacer(
+	"shch", "sch",
+	"kh", "h",
+	"ts", "c",
+	"yu", "iu",
+	"ya", "ia",
+	"y", "i",
+	"j", "i",
+	"w", "v",
+)
+
+// TranslitKey возвращает ключ для сравнения тегов без учета алфавита:
+// «котики», «kotiki» и «kotyki» дают один ключ. tag должен быть уже
+// нормализован NormalizeTag
+func TranslitKey(tag string) string {
+	var b strings.Builder
+	for _, r := range tag {
+		if latin, ok := translit[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return translitFolds.Replace(b.String())
 }